@@ -22,12 +22,15 @@ package client
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"strings"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
@@ -37,94 +40,233 @@ import (
 type Config struct {
 	Config    *rest.Config
 	Namespace string
+	// LabelSelector narrows the listed objects by labels, e.g. "app=web,tier!=db",
+	// empty to list all objects.
+	LabelSelector string
+	// FieldSelector narrows the listed objects by fields, e.g. "status.phase=Running", requirements
+	// on fields the server can not select the listed resource by are ignored.
+	FieldSelector string
+	// ChunkSize is the maximum number of objects listed in one response, 0 lists all objects in one response.
+	ChunkSize int64
+	// Chunk is called for each chunk of listed objects, and returns the objects to keep, e.g. the
+	// objects matching a query, nil keeps all listed objects.
+	Chunk func(items []unstructured.Unstructured) ([]unstructured.Unstructured, error)
+	// Limit stops listing once Limit objects are kept, 0 lists all objects.
+	Limit int
+	// Discovery looks up resources, e.g. a disk cached discovery client, nil looks up
+	// resources using a discovery client of Config that does not cache the results.
+	Discovery discovery.DiscoveryInterface
 }
 
-// List resources by resource name.
-func (c Config) List(ctx context.Context, resourceName string) ([]unstructured.Unstructured, error) {
-	var err error
-	var list *unstructured.UnstructuredList
-
+// List resources by resource name, the returned flag is true if listing stopped once Limit
+// objects were kept, and objects left to list may be kept.
+func (c Config) List(ctx context.Context, resourceName string) ([]unstructured.Unstructured, bool, error) {
 	resource, group, version, err := c.getResourceGroupVersion(resourceName)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	dynamicClient, err := dynamic.NewForConfig(c.Config)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// Get all resource objects.
-	res := dynamicClient.Resource(schema.GroupVersionResource{
+	gvr := schema.GroupVersionResource{
 		Group:    group,
 		Version:  version,
 		Resource: resource.Name,
-	})
+	}
+	res := dynamicClient.Resource(gvr)
+
+	// Select only by fields the server can select this resource by.
+	c.FieldSelector = SelectFields(c.FieldSelector, gvr)
 
 	// Check for namespace
+	if IsNamespacePattern(c.Namespace) && resource.Namespaced {
+		return c.listNamespaces(ctx, dynamicClient, res)
+	}
 	if len(c.Namespace) > 0 && c.Namespace != "*" && resource.Namespaced {
-		list, err = res.Namespace(c.Namespace).List(ctx, v1.ListOptions{})
-	} else {
-		list, err = res.List(ctx, v1.ListOptions{})
+		return c.list(ctx, res.Namespace(c.Namespace))
+	}
+
+	return c.list(ctx, res)
+}
+
+// listOptions returns the options used to list objects.
+func (c Config) listOptions() v1.ListOptions {
+	return v1.ListOptions{
+		LabelSelector: c.LabelSelector,
+		FieldSelector: c.FieldSelector,
+		Limit:         c.ChunkSize,
 	}
+}
 
+// Resolve looks up the group version resource of a resource name, and if it is namespaced,
+// using the discovery API, without listing objects.
+func (c Config) Resolve(resourceName string) (schema.GroupVersionResource, bool, error) {
+	resource, group, version, err := c.getResourceGroupVersion(resourceName)
 	if err != nil {
-		return nil, err
+		return schema.GroupVersionResource{}, false, err
 	}
 
-	return list.Items, err
+	return schema.GroupVersionResource{Group: group, Version: version, Resource: resource.Name}, resource.Namespaced, nil
 }
 
-// Look for a resource matching request resource name.
-func (c Config) getResourceGroupVersion(resourceName string) (v1.APIResource, string, string, error) {
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(c.Config)
+// Kind looks up the kind of a resource name using the discovery API, e.g. "Pod" for "pods".
+func (c Config) Kind(resourceName string) (string, error) {
+	resource, _, _, err := c.getResourceGroupVersion(resourceName)
 	if err != nil {
-		return v1.APIResource{}, "", "", err
+		return "", err
 	}
 
-	resources, err := discoveryClient.ServerPreferredResources()
+	return resource.Kind, nil
+}
+
+// Look for a resource matching request resource name, the name may be qualified by group
+// and version, e.g. "deployments", "deployments.apps", "deployments.v1.apps" or "apps/v1/deployments".
+func (c Config) getResourceGroupVersion(resourceName string) (v1.APIResource, string, string, error) {
+	names, err := parseResourceName(resourceName)
 	if err != nil {
 		return v1.APIResource{}, "", "", err
 	}
 
-	// Search for a matching resource
-	resource := v1.APIResource{}
-	resourceList := &v1.APIResourceList{}
-	for _, rl := range resources {
-		for _, r := range rl.APIResources {
-			names := append(r.ShortNames, r.Name)
-			if stringInSlice(resourceName, names) {
-				resource = r
-				resourceList = rl
+	discoveryClient := c.Discovery
+	if discoveryClient == nil {
+		if discoveryClient, err = discovery.NewDiscoveryClientForConfig(c.Config); err != nil {
+			return v1.APIResource{}, "", "", err
+		}
+	}
+
+	resource, group, version, err := findResourceGroupVersion(discoveryClient, names)
+	if err == nil {
+		return resource, group, version, nil
+	}
+
+	// Cached resources may be missing resources created after they were cached, e.g. new CRDs,
+	// invalidate the cache and look again.
+	if cached, ok := discoveryClient.(discovery.CachedDiscoveryInterface); ok && !cached.Fresh() {
+		cached.Invalidate()
+		resource, group, version, err = findResourceGroupVersion(discoveryClient, names)
+		if err == nil {
+			return resource, group, version, nil
+		}
+	}
+
+	if err == errResourceNotFound {
+		return v1.APIResource{}, "", "", fmt.Errorf("Failed to find resource: %s", resourceName)
+	}
+	return v1.APIResource{}, "", "", fmt.Errorf("Failed to find resource: %s, %v", resourceName, err)
+}
+
+// errResourceNotFound is returned when no discovered resource matches a resource name.
+var errResourceNotFound = fmt.Errorf("resource not found")
+
+// findResourceGroupVersion looks for a discovered resource matching the possible meanings of a resource
+// name. Groups that fail discovery, e.g. unavailable aggregated APIs, are skipped, and if the resource
+// is not found in the other groups, the discovery failure is returned.
+func findResourceGroupVersion(discoveryClient discovery.DiscoveryInterface, names []resourceName) (v1.APIResource, string, string, error) {
+	var groups *v1.APIGroupList
+	var discoveryErr error
+	for _, name := range names {
+		// Look for resources of a requested version in the group version resource list.
+		if name.version != "" {
+			gv := schema.GroupVersion{Group: name.group, Version: name.version}
+			resourceList, err := discoveryClient.ServerResourcesForGroupVersion(gv.String())
+			if err != nil {
+				// Group versions the server does not serve are not found, other failures are kept.
+				if !errors.IsNotFound(err) && err != memory.ErrCacheNotFound {
+					discoveryErr = fmt.Errorf("unable to retrieve the resources of %s: %v", gv, err)
+				}
+				continue
+			}
+			if resource, ok := findResource(resourceList, name.resource); ok {
+				return resource, name.group, name.version, nil
+			}
+			continue
+		}
+
+		// Look for other resources in the preferred versions of each group, groups are
+		// looked up in turn, so that failing groups are only looked up when reached.
+		if groups == nil {
+			var err error
+			if groups, err = discoveryClient.ServerGroups(); err != nil {
+				return v1.APIResource{}, "", "", err
 			}
 		}
+		for _, group := range groups.Groups {
+			if name.grouped && group.Name != name.group {
+				continue
+			}
 
-		if len(resource.Name) > 0 {
-			break
+			resourceList, err := discoveryClient.ServerResourcesForGroupVersion(group.PreferredVersion.GroupVersion)
+			if err != nil {
+				discoveryErr = fmt.Errorf("unable to retrieve the resources of %s: %v", group.PreferredVersion.GroupVersion, err)
+				continue
+			}
+			if resource, ok := findResource(resourceList, name.resource); ok {
+				return resource, group.Name, group.PreferredVersion.Version, nil
+			}
 		}
 	}
 
-	if len(resource.Name) == 0 {
-		return v1.APIResource{}, "", "", fmt.Errorf("Failed to find resource")
+	if discoveryErr != nil {
+		return v1.APIResource{}, "", "", discoveryErr
 	}
+	return v1.APIResource{}, "", "", errResourceNotFound
+}
 
-	group, version := getGroupVersion(resourceList)
-	return resource, group, version, nil
+// resourceName is a requested resource name, optionally qualified by group and version.
+type resourceName struct {
+	resource string
+	group    string
+	version  string
+	// grouped is true if the name is qualified by group, the group of core resources is "".
+	grouped bool
 }
 
-// Get resource group and version.
-func getGroupVersion(resourceList *v1.APIResourceList) (string, string) {
-	group := ""
-	version := ""
-	resourceGroupSplit := strings.Split(resourceList.GroupVersion, "/")
-	if len(resourceGroupSplit) == 2 {
-		group = resourceGroupSplit[0]
-		version = resourceGroupSplit[1]
-	} else {
-		version = resourceGroupSplit[0]
+// parseResourceName returns the possible meanings of a resource name, in the order they should be
+// looked up, e.g. "deployments.v1.apps" is the v1 version of deployments in the apps group, or
+// deployments in the "v1.apps" group.
+func parseResourceName(s string) ([]resourceName, error) {
+	// Group, version and resource, e.g. "apps/v1/deployments", or "core/v1/pods" for core resources.
+	if strings.Contains(s, "/") {
+		parts := strings.Split(s, "/")
+		if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid resource name: %s, expected group/version/resource", s)
+		}
+
+		group := parts[0]
+		if group == "core" {
+			group = ""
+		}
+		return []resourceName{{resource: parts[2], group: group, version: parts[1], grouped: true}}, nil
+	}
+
+	// Resource, optionally followed by version and group, e.g. "deployments.v1.apps" or "deployments.apps".
+	parts := strings.SplitN(s, ".", 3)
+	names := []resourceName{}
+	if len(parts) == 3 && versionPattern.MatchString(parts[1]) {
+		names = append(names, resourceName{resource: parts[0], group: parts[2], version: parts[1], grouped: true})
+	}
+	if len(parts) > 1 {
+		return append(names, resourceName{resource: parts[0], group: strings.Join(parts[1:], "."), grouped: true}), nil
 	}
 
-	return group, version
+	return []resourceName{{resource: s}}, nil
+}
+
+// versionPattern matches kubernetes API versions, e.g. v1, v1beta1 or v2alpha3.
+var versionPattern = regexp.MustCompile(`^v[0-9]+((alpha|beta)[0-9]+)?$`)
+
+// findResource looks for a resource by name or short name in a resource list.
+func findResource(resourceList *v1.APIResourceList, name string) (v1.APIResource, bool) {
+	for _, r := range resourceList.APIResources {
+		if r.Name == name || stringInSlice(name, r.ShortNames) {
+			return r, true
+		}
+	}
+	return v1.APIResource{}, false
 }
 
 // Check if a string in slice of strings.
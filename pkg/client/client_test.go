@@ -0,0 +1,131 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestParseResourceName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []resourceName
+		wantErr bool
+	}{
+		{name: "resource", input: "pods", want: []resourceName{{resource: "pods"}}},
+		{name: "group", input: "deployments.apps", want: []resourceName{{resource: "deployments", group: "apps", grouped: true}}},
+		{
+			name:  "dotted group",
+			input: "events.events.k8s.io",
+			want:  []resourceName{{resource: "events", group: "events.k8s.io", grouped: true}},
+		},
+		{
+			name:  "version and group",
+			input: "deployments.v1.apps",
+			want: []resourceName{
+				{resource: "deployments", group: "apps", version: "v1", grouped: true},
+				{resource: "deployments", group: "v1.apps", grouped: true},
+			},
+		},
+		{
+			name:  "beta version",
+			input: "cronjobs.v1beta1.batch",
+			want: []resourceName{
+				{resource: "cronjobs", group: "batch", version: "v1beta1", grouped: true},
+				{resource: "cronjobs", group: "v1beta1.batch", grouped: true},
+			},
+		},
+		{
+			name:  "group version resource",
+			input: "apps/v1/deployments",
+			want:  []resourceName{{resource: "deployments", group: "apps", version: "v1", grouped: true}},
+		},
+		{
+			name:  "core group version resource",
+			input: "core/v1/pods",
+			want:  []resourceName{{resource: "pods", version: "v1", grouped: true}},
+		},
+		{name: "missing version", input: "apps//deployments", wantErr: true},
+		{name: "missing resource", input: "apps/v1/", wantErr: true},
+		{name: "too many parts", input: "apps/v1/deployments/x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseResourceName(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseResourceName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseResourceName() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// failingDiscovery is a fake discovery client, failing to look up the resources of some group versions.
+type failingDiscovery struct {
+	*fake.FakeDiscovery
+	failed map[string]error
+}
+
+func (d failingDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*v1.APIResourceList, error) {
+	if err, ok := d.failed[groupVersion]; ok {
+		return nil, err
+	}
+	return d.FakeDiscovery.ServerResourcesForGroupVersion(groupVersion)
+}
+
+func TestResolve(t *testing.T) {
+	d := failingDiscovery{
+		FakeDiscovery: &fake.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*v1.APIResourceList{
+			{GroupVersion: "v1", APIResources: []v1.APIResource{{Name: "pods", Namespaced: true, ShortNames: []string{"po"}}}},
+			{GroupVersion: "apps/v1", APIResources: []v1.APIResource{{Name: "deployments", Namespaced: true, ShortNames: []string{"deploy"}}}},
+			{GroupVersion: "metrics.k8s.io/v1beta1", APIResources: []v1.APIResource{{Name: "nodes"}}},
+		}}},
+		failed: map[string]error{
+			"metrics.k8s.io/v1beta1": errors.NewServiceUnavailable("the server is currently unable to handle the request"),
+		},
+	}
+	c := Config{Discovery: d}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    schema.GroupVersionResource
+		wantErr string
+	}{
+		{name: "core resource", input: "pods", want: schema.GroupVersionResource{Version: "v1", Resource: "pods"}},
+		{name: "short name", input: "deploy", want: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}},
+		{name: "group", input: "deployments.apps", want: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}},
+		{name: "group version", input: "apps/v1/deployments", want: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}},
+		{name: "not served version", input: "apps/v2/deployments", wantErr: "Failed to find resource: apps/v2/deployments"},
+		{name: "unknown resource", input: "widgets", wantErr: "Failed to find resource: widgets, unable to retrieve the resources of metrics.k8s.io/v1beta1: the server is currently unable to handle the request"},
+		{name: "failed group", input: "nodes.metrics.k8s.io", wantErr: "Failed to find resource: nodes.metrics.k8s.io, unable to retrieve the resources of metrics.k8s.io/v1beta1: the server is currently unable to handle the request"},
+		{name: "failed group version", input: "metrics.k8s.io/v1beta1/nodes", wantErr: "Failed to find resource: metrics.k8s.io/v1beta1/nodes, unable to retrieve the resources of metrics.k8s.io/v1beta1: the server is currently unable to handle the request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := c.Resolve(tt.input)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Resolve() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
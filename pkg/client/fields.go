@@ -0,0 +1,61 @@
+package client
+
+import (
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// selectableFields are the fields the server can select objects of a resource by,
+// besides the metadata.name and metadata.namespace fields of all resources.
+var selectableFields = map[schema.GroupResource][]string{
+	{Resource: "pods"}: {
+		"spec.nodeName", "spec.restartPolicy", "spec.schedulerName", "spec.serviceAccountName",
+		"status.phase", "status.podIP", "status.nominatedNodeName",
+	},
+	{Resource: "events"}: {
+		"involvedObject.kind", "involvedObject.namespace", "involvedObject.name", "involvedObject.uid",
+		"involvedObject.apiVersion", "involvedObject.resourceVersion", "involvedObject.fieldPath",
+		"reason", "reportingComponent", "source", "type",
+	},
+	{Resource: "secrets"}:    {"type"},
+	{Resource: "namespaces"}: {"status.phase"},
+	{Group: "certificates.k8s.io", Resource: "certificatesigningrequests"}: {"spec.signerName"},
+}
+
+// SelectableFields returns the fields the server can select objects of a resource by.
+func SelectableFields(gvr schema.GroupVersionResource) map[string]bool {
+	names := map[string]bool{"metadata.name": true, "metadata.namespace": true}
+	for _, name := range selectableFields[gvr.GroupResource()] {
+		names[name] = true
+	}
+	return names
+}
+
+// SelectFields returns the requirements of a field selector on fields the server can
+// select objects of a resource by, e.g. "status.phase=Running" for pods.
+func SelectFields(selector string, gvr schema.GroupVersionResource) string {
+	if selector == "" {
+		return ""
+	}
+	s, err := fields.ParseSelector(selector)
+	if err != nil {
+		return ""
+	}
+
+	selectable := SelectableFields(gvr)
+	selectors := []fields.Selector{}
+	for _, r := range s.Requirements() {
+		if !selectable[r.Field] {
+			continue
+		}
+
+		switch r.Operator {
+		case selection.Equals, selection.DoubleEquals:
+			selectors = append(selectors, fields.OneTermEqualSelector(r.Field, r.Value))
+		case selection.NotEquals:
+			selectors = append(selectors, fields.OneTermNotEqualSelector(r.Field, r.Value))
+		}
+	}
+	return fields.AndSelectors(selectors...).String()
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestSelectFields(t *testing.T) {
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	events := schema.GroupVersionResource{Version: "v1", Resource: "events"}
+	secrets := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+
+	tests := []struct {
+		name     string
+		selector string
+		gvr      schema.GroupVersionResource
+		want     string
+	}{
+		{name: "empty", selector: "", gvr: pods, want: ""},
+		{name: "pod fields", selector: "status.phase=Running,spec.nodeName!=worker-1", gvr: pods, want: "spec.nodeName!=worker-1,status.phase=Running"},
+		{name: "metadata fields", selector: "metadata.name=web-0,metadata.namespace!=test", gvr: secrets, want: "metadata.name=web-0,metadata.namespace!=test"},
+		{name: "event fields", selector: "type=Warning,reason=BackOff,involvedObject.kind=Pod", gvr: events, want: "involvedObject.kind=Pod,reason=BackOff,type=Warning"},
+		{name: "secret type", selector: "type=Opaque", gvr: secrets, want: "type=Opaque"},
+		{name: "not selectable", selector: "type=Warning,status.phase=Running", gvr: secrets, want: "type=Warning"},
+		{name: "other resource", selector: "type=Warning,status.phase=Running", gvr: pods, want: "status.phase=Running"},
+		{name: "invalid selector", selector: "type", gvr: events, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SelectFields(tt.selector, tt.gvr); got != tt.want {
+				t.Errorf("SelectFields() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// maxListRestarts is the number of times listing restarts when a continue token expires.
+const maxListRestarts = 3
+
+// list lists the objects of a resource narrowed by the label and field selectors, if the server
+// rejects the selectors, e.g. a field it can not select by, all objects are listed.
+func (c Config) list(ctx context.Context, res dynamic.ResourceInterface) ([]unstructured.Unstructured, bool, error) {
+	items, more, err := c.listChunks(ctx, res, c.listOptions())
+	if err != nil && errors.IsBadRequest(err) && (c.LabelSelector != "" || c.FieldSelector != "") {
+		items, more, err = c.listChunks(ctx, res, v1.ListOptions{Limit: c.ChunkSize})
+	}
+	return items, more, err
+}
+
+// listChunks lists the objects of a resource chunk by chunk, using the continue token of each chunk
+// to list the next one, until Limit objects are kept. If a continue token expires before all chunks
+// are listed, listing restarts. The returned flag is true if listing stopped with objects left
+// to list, that may be kept.
+func (c Config) listChunks(ctx context.Context, res dynamic.ResourceInterface, opts v1.ListOptions) ([]unstructured.Unstructured, bool, error) {
+	items := []unstructured.Unstructured{}
+	restarts := 0
+	for {
+		list, err := res.List(ctx, opts)
+		if err != nil && opts.Continue != "" && (errors.IsResourceExpired(err) || errors.IsGone(err)) && restarts < maxListRestarts {
+			// The server no longer has the listed version of the collection, list it again from the start.
+			restarts++
+			items, opts.Continue = []unstructured.Unstructured{}, ""
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		chunk := list.Items
+		if c.Chunk != nil {
+			if chunk, err = c.Chunk(chunk); err != nil {
+				return nil, false, err
+			}
+		}
+		items = append(items, chunk...)
+
+		if c.Limit > 0 && len(items) >= c.Limit {
+			return items[:c.Limit], len(items) > c.Limit || list.GetContinue() != "", nil
+		}
+		if list.GetContinue() == "" {
+			return items, false, nil
+		}
+		opts.Continue = list.GetContinue()
+	}
+}
@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var podsResource = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+// recordingResource records the options of list requests, the fake client does not pass
+// the limit and continue token of list options to reactors.
+type recordingResource struct {
+	dynamic.ResourceInterface
+	requests []v1.ListOptions
+}
+
+func (r *recordingResource) List(ctx context.Context, opts v1.ListOptions) (*unstructured.UnstructuredList, error) {
+	r.requests = append(r.requests, opts)
+	return r.ResourceInterface.List(ctx, opts)
+}
+
+// newChunkedResource returns a pods resource of a fake client listing pods in chunks, continue
+// tokens are the index of the first pod of the next chunk. Requests listed in expired return a
+// 410 Gone error, and requests listed in badRequest a 400 Bad Request error.
+func newChunkedResource(pods int, expired map[int]bool, badRequest map[int]bool) *recordingResource {
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{podsResource: "PodList"})
+	r := &recordingResource{ResourceInterface: client.Resource(podsResource)}
+
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		request := len(r.requests)
+		opts := r.requests[request-1]
+		if expired[request] {
+			return true, nil, errors.NewResourceExpired("continue token expired")
+		}
+		if badRequest[request] {
+			return true, nil, errors.NewBadRequest("unable to parse field selector")
+		}
+
+		start, _ := strconv.Atoi(opts.Continue)
+		end := pods
+		next := ""
+		if opts.Limit > 0 && start+int(opts.Limit) < pods {
+			end = start + int(opts.Limit)
+			next = strconv.Itoa(end)
+		}
+
+		list := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "PodList"}}
+		list.SetContinue(next)
+		for i := start; i < end; i++ {
+			pod := unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Pod"}}
+			pod.SetName(fmt.Sprintf("pod-%d", i))
+			list.Items = append(list.Items, pod)
+		}
+		return true, list, nil
+	})
+	return r
+}
+
+func itemNames(items []unstructured.Unstructured) []string {
+	names := []string{}
+	for _, item := range items {
+		names = append(names, item.GetName())
+	}
+	return names
+}
+
+func TestListChunks(t *testing.T) {
+	evenPods := func(items []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+		kept := []unstructured.Unstructured{}
+		for _, item := range items {
+			if n, _ := strconv.Atoi(item.GetName()[len("pod-"):]); n%2 == 0 {
+				kept = append(kept, item)
+			}
+		}
+		return kept, nil
+	}
+
+	tests := []struct {
+		name          string
+		config        Config
+		pods          int
+		expired       map[int]bool
+		badRequest    map[int]bool
+		want          []string
+		wantMore      bool
+		wantContinues []string
+		wantErr       bool
+	}{
+		{
+			name:          "one response",
+			pods:          3,
+			want:          []string{"pod-0", "pod-1", "pod-2"},
+			wantContinues: []string{""},
+		},
+		{
+			name:          "chunks",
+			config:        Config{ChunkSize: 2},
+			pods:          5,
+			want:          []string{"pod-0", "pod-1", "pod-2", "pod-3", "pod-4"},
+			wantContinues: []string{"", "2", "4"},
+		},
+		{
+			name:          "filtered chunks",
+			config:        Config{ChunkSize: 2, Chunk: evenPods},
+			pods:          5,
+			want:          []string{"pod-0", "pod-2", "pod-4"},
+			wantContinues: []string{"", "2", "4"},
+		},
+		{
+			name:          "limit",
+			config:        Config{ChunkSize: 2, Chunk: evenPods, Limit: 2},
+			pods:          10,
+			want:          []string{"pod-0", "pod-2"},
+			wantMore:      true,
+			wantContinues: []string{"", "2"},
+		},
+		{
+			name:          "limit in the last chunk",
+			config:        Config{ChunkSize: 2, Chunk: evenPods, Limit: 2},
+			pods:          4,
+			want:          []string{"pod-0", "pod-2"},
+			wantContinues: []string{"", "2"},
+		},
+		{
+			name:          "limit in a chunk",
+			config:        Config{Limit: 2},
+			pods:          3,
+			want:          []string{"pod-0", "pod-1"},
+			wantMore:      true,
+			wantContinues: []string{""},
+		},
+		{
+			name:          "expired continue token",
+			config:        Config{ChunkSize: 2},
+			pods:          5,
+			expired:       map[int]bool{3: true},
+			want:          []string{"pod-0", "pod-1", "pod-2", "pod-3", "pod-4"},
+			wantContinues: []string{"", "2", "4", "", "2", "4"},
+		},
+		{
+			name:          "expired too many times",
+			config:        Config{ChunkSize: 2},
+			pods:          5,
+			expired:       map[int]bool{2: true, 4: true, 6: true, 8: true},
+			wantContinues: []string{"", "2", "", "2", "", "2", "", "2"},
+			wantErr:       true,
+		},
+		{
+			name:          "rejected selectors",
+			config:        Config{ChunkSize: 2, FieldSelector: "spec.unknown=x"},
+			pods:          3,
+			badRequest:    map[int]bool{1: true},
+			want:          []string{"pod-0", "pod-1", "pod-2"},
+			wantContinues: []string{"", "", "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newChunkedResource(tt.pods, tt.expired, tt.badRequest)
+
+			items, more, err := tt.config.list(context.Background(), r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("list() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(itemNames(items), tt.want) {
+				t.Errorf("list() items = %v, want %v", itemNames(items), tt.want)
+			}
+			if more != tt.wantMore {
+				t.Errorf("list() more = %v, want %v", more, tt.wantMore)
+			}
+
+			continues := []string{}
+			for _, opts := range r.requests {
+				if opts.Limit != tt.config.ChunkSize {
+					t.Errorf("list() limit = %d, want %d", opts.Limit, tt.config.ChunkSize)
+				}
+				continues = append(continues, opts.Continue)
+			}
+			if !reflect.DeepEqual(continues, tt.wantContinues) {
+				t.Errorf("list() continue tokens = %q, want %q", continues, tt.wantContinues)
+			}
+		})
+	}
+}
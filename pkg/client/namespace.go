@@ -0,0 +1,201 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// NamespacePattern matches namespace names, e.g. "team-*", "{prod,staging}" or "!kube-*".
+type NamespacePattern struct {
+	// patterns are glob patterns, a namespace matches if it matches any of them.
+	patterns []string
+	// negate is true for patterns starting with "!", matching namespaces that do not match the patterns.
+	negate bool
+}
+
+// namespacePatternElement matches a namespace name that may contain glob characters.
+var namespacePatternElement = regexp.MustCompile(`^[a-z0-9\-*?\[\]^]+$`)
+
+// IsNamespacePattern checks if a namespace is a set or glob pattern rather than a namespace name,
+// "*" is not a pattern, it is the special value for all namespaces.
+func IsNamespacePattern(namespace string) bool {
+	return namespace != "*" && strings.ContainsAny(namespace, "*?[{!")
+}
+
+// ParseNamespacePattern parses a namespace set or glob pattern, sets are comma separated
+// lists in braces, e.g. "{prod,staging}" or "team-{a,b}-*", and a leading "!" negates the pattern.
+func ParseNamespacePattern(s string) (NamespacePattern, error) {
+	p := NamespacePattern{}
+
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "!") {
+		p.negate = true
+		s = s[1:]
+	}
+
+	patterns, err := expandBraces(s)
+	if err != nil {
+		return p, err
+	}
+	for _, pattern := range patterns {
+		if !namespacePatternElement.MatchString(pattern) {
+			return p, fmt.Errorf("invalid namespace pattern: %s", s)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return p, fmt.Errorf("invalid namespace pattern: %s", s)
+		}
+	}
+	p.patterns = patterns
+
+	return p, nil
+}
+
+// expandBraces expands the sets of a pattern, e.g. "{a,b}-*" expands to "a-*" and "b-*".
+func expandBraces(s string) ([]string, error) {
+	start := strings.Index(s, "{")
+	if start == -1 {
+		if strings.Contains(s, "}") {
+			return nil, fmt.Errorf("invalid namespace pattern, unexpected }: %s", s)
+		}
+		return []string{s}, nil
+	}
+
+	end := strings.Index(s[start:], "}")
+	if end == -1 {
+		return nil, fmt.Errorf("invalid namespace pattern, expected }: %s", s)
+	}
+	end += start
+
+	set := s[start+1 : end]
+	if strings.Contains(set, "{") {
+		return nil, fmt.Errorf("invalid namespace pattern, nested sets are not supported: %s", s)
+	}
+
+	// Expand the rest of the pattern first, then prefix it with each element of the set.
+	rest, err := expandBraces(s[end+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := []string{}
+	for _, e := range strings.Split(set, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			return nil, fmt.Errorf("invalid namespace pattern, empty set element: %s", s)
+		}
+		for _, r := range rest {
+			patterns = append(patterns, s[:start]+e+r)
+		}
+	}
+	return patterns, nil
+}
+
+// Match checks if a namespace name matches the pattern.
+func (p NamespacePattern) Match(namespace string) bool {
+	for _, pattern := range p.patterns {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return !p.negate
+		}
+	}
+	return p.negate
+}
+
+// names returns the namespace names of patterns that are sets of names without globs,
+// e.g. "{prod,staging}", the namespaces of these patterns can be listed without discovery.
+// Repeated names, e.g. "{prod,prod}", are returned once.
+func (p NamespacePattern) names() ([]string, bool) {
+	if p.negate {
+		return nil, false
+	}
+	names := []string{}
+	seen := map[string]bool{}
+	for _, pattern := range p.patterns {
+		if strings.ContainsAny(pattern, "*?[") {
+			return nil, false
+		}
+		if !seen[pattern] {
+			seen[pattern] = true
+			names = append(names, pattern)
+		}
+	}
+	return names, true
+}
+
+// listNamespaces lists the objects of the namespaces matching a namespace pattern. Sets of names are
+// listed namespace by namespace, other patterns are listed cluster-wide and filtered, if listing
+// cluster-wide is forbidden, the namespaces are listed and each matching namespace is listed.
+func (c Config) listNamespaces(ctx context.Context, dynamicClient dynamic.Interface, res dynamic.NamespaceableResourceInterface) ([]unstructured.Unstructured, bool, error) {
+	pattern, err := ParseNamespacePattern(c.Namespace)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if names, ok := pattern.names(); ok {
+		return c.listEach(ctx, res, names)
+	}
+
+	// Objects of other namespaces are dropped from each chunk, before the chunk is processed.
+	wide := c
+	wide.Chunk = func(items []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+		matched := []unstructured.Unstructured{}
+		for _, item := range items {
+			if pattern.Match(item.GetNamespace()) {
+				matched = append(matched, item)
+			}
+		}
+		if c.Chunk == nil {
+			return matched, nil
+		}
+		return c.Chunk(matched)
+	}
+	list, more, err := wide.list(ctx, res)
+	if err == nil {
+		return list, more, nil
+	}
+	if !errors.IsForbidden(err) {
+		return nil, false, err
+	}
+
+	// Listing cluster-wide is forbidden, look for the matching namespaces instead.
+	namespaces, nsErr := dynamicClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}).List(ctx, v1.ListOptions{})
+	if nsErr != nil {
+		return nil, false, err
+	}
+
+	names := []string{}
+	for _, ns := range namespaces.Items {
+		if pattern.Match(ns.GetName()) {
+			names = append(names, ns.GetName())
+		}
+	}
+	return c.listEach(ctx, res, names)
+}
+
+// listEach lists the objects of each namespace, until Limit objects are kept.
+func (c Config) listEach(ctx context.Context, res dynamic.NamespaceableResourceInterface, namespaces []string) ([]unstructured.Unstructured, bool, error) {
+	items := []unstructured.Unstructured{}
+	for i, ns := range namespaces {
+		each := c
+		if c.Limit > 0 {
+			each.Limit = c.Limit - len(items)
+		}
+		list, more, err := each.list(ctx, res.Namespace(ns))
+		if err != nil {
+			return nil, false, err
+		}
+		items = append(items, list...)
+		if c.Limit > 0 && len(items) >= c.Limit {
+			return items, more || i < len(namespaces)-1, nil
+		}
+	}
+	return items, false, nil
+}
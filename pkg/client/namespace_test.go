@@ -0,0 +1,106 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNamespacePattern(t *testing.T) {
+	namespaces := []string{"prod", "staging", "team-a", "team-b-dev", "kube-system", "default"}
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    []string
+		wantErr bool
+	}{
+		{name: "glob", pattern: "team-*", want: []string{"team-a", "team-b-dev"}},
+		{name: "single character", pattern: "team-?", want: []string{"team-a"}},
+		{name: "character class", pattern: "[ps]*", want: []string{"prod", "staging"}},
+		{name: "set", pattern: "{prod,staging}", want: []string{"prod", "staging"}},
+		{name: "set with spaces", pattern: "{prod, staging}", want: []string{"prod", "staging"}},
+		{name: "set and glob", pattern: "team-{a,b}*", want: []string{"team-a", "team-b-dev"}},
+		{name: "negated glob", pattern: "!kube-*", want: []string{"prod", "staging", "team-a", "team-b-dev", "default"}},
+		{name: "negated set", pattern: "!{prod,default}", want: []string{"staging", "team-a", "team-b-dev", "kube-system"}},
+		{name: "no match", pattern: "dev-*", want: []string{}},
+		{name: "unterminated set", pattern: "{prod,staging", wantErr: true},
+		{name: "unexpected brace", pattern: "prod}", wantErr: true},
+		{name: "nested sets", pattern: "{a,{b,c}}", wantErr: true},
+		{name: "empty set element", pattern: "{prod,}", wantErr: true},
+		{name: "invalid character", pattern: "Prod*", wantErr: true},
+		{name: "invalid glob", pattern: "team-[a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParseNamespacePattern(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNamespacePattern() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := []string{}
+			for _, ns := range namespaces {
+				if p.Match(ns) {
+					got = append(got, ns)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Match() namespaces = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsNamespacePattern(t *testing.T) {
+	tests := []struct {
+		namespace string
+		want      bool
+	}{
+		{namespace: "default", want: false},
+		{namespace: "*", want: false},
+		{namespace: "team-*", want: true},
+		{namespace: "{prod,staging}", want: true},
+		{namespace: "!kube-system", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			if got := IsNamespacePattern(tt.namespace); got != tt.want {
+				t.Errorf("IsNamespacePattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespacePatternNames(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+		wantOk  bool
+	}{
+		{pattern: "{prod,staging}", want: []string{"prod", "staging"}, wantOk: true},
+		{pattern: "{default,default}", want: []string{"default"}, wantOk: true},
+		{pattern: "team-{a,b,a}", want: []string{"team-a", "team-b"}, wantOk: true},
+		{pattern: "team-*", wantOk: false},
+		{pattern: "!{prod,staging}", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			p, err := ParseNamespacePattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("ParseNamespacePattern() error = %v", err)
+			}
+			got, ok := p.names()
+			if ok != tt.wantOk {
+				t.Fatalf("names() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("names() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
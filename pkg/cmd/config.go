@@ -20,10 +20,17 @@ Author: 2020 Yaacov Zamir <kobi.zamir@gmail.com>
 package cmd
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/yaacov/tree-search-language/v6/pkg/walkers/semantics"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/tools/clientcmd"
 
+	"github.com/yaacov/kubectl-sql/pkg/filter"
 	"github.com/yaacov/kubectl-sql/pkg/printers"
 )
 
@@ -34,15 +41,64 @@ type SQLOptions struct {
 
 	rawConfig clientcmd.ClientConfig
 	args      []string
+	// paramArgs are the query parameters given as name=value, and params their typed values.
+	paramArgs []string
+	params    map[string]interface{}
+	// filename is the query script file, "-" for the standard input, empty when running a single query.
+	filename        string
+	continueOnError bool
+	// chunkSize is the maximum number of items listed in one response, 0 lists all items in one response.
+	chunkSize int64
+	// refreshDiscovery invalidates the cached resources of the server before running queries.
+	refreshDiscovery bool
+	// discoveryClient looks up resources using the disk cache, shared by all the queries of a run,
+	// nil looks up resources without caching.
+	discoveryClient discovery.CachedDiscoveryInterface
+	// explain is true for EXPLAIN queries, that print the query plan instead of listing items.
+	explain bool
 
 	defaultAliases     map[string]string
 	defaultTableFields printers.TableFieldsMap
 	orderByFields      []printers.OrderByField
-	limit              int
+	// orderByExprs are the ORDER BY expressions of queries selecting "*", keyed by the
+	// placeholder identifiers used as sort keys.
+	orderByExprs map[string]orderByExpr
+	limit        int
+	offset       int
+	// partial is true if listing stopped once enough items matched, and more items may match.
+	partial bool
+	// resourceKind is the kind of the requested resources, the kind of aggregated rows
+	// of queries matching no items, empty for other queries.
+	resourceKind string
 
 	namespace          string
-	requestedResources []string
+	requestedResources []requestedResource
+	requestedJoin      *requestedJoin
 	requestedQuery     string
+	requestedGroupBy   *requestedGroupBy
+	requestedColumns   *requestedColumns
+	requestedCalls     map[string]filter.Call
+	distinct           bool
+	// kindColumn is true if the printed table starts with the kind of each row.
+	kindColumn bool
+
+	// requestedSubqueries are the subqueries of the WHERE clause, keyed by the
+	// placeholder identifiers that replace them in the query.
+	requestedSubqueries map[string]*requestedSubquery
+	// outer is the enclosing query of a subquery, nil for the main query.
+	outer *SQLOptions
+	// requestedUnion are the queries combined using UNION [ALL], nil for single queries.
+	requestedUnion *requestedUnion
+	// requestedCTEs are the named queries of the WITH clause, keyed by lower case name,
+	// shared by all the queries of a statement.
+	requestedCTEs map[string]*requestedCTE
+	// rowTitles are the column titles of the rows of a named query selecting fields,
+	// used as field names without resolving aliases, nil for other queries.
+	rowTitles map[string]bool
+
+	// evalFunctionFactory builds the key evaluation method for result items,
+	// nil for the default evaluation of kubernetes resources.
+	evalFunctionFactory func(item unstructured.Unstructured) semantics.EvalFunc
 
 	outputFormat string
 	noHeaders    bool
@@ -50,21 +106,169 @@ type SQLOptions struct {
 	genericclioptions.IOStreams
 }
 
+// requestedResource is a resource requested in the FROM or JOIN clauses.
+type requestedResource struct {
+	name      string
+	namespace string
+	// alias is the table name used to qualify field references.
+	alias string
+	// cte is the named query of the WITH clause used as resource, nil for kubernetes resources.
+	cte *requestedCTE
+}
+
+// requestedJoin is a resource joined to the FROM resource.
+type requestedJoin struct {
+	resource requestedResource
+	// left is true for LEFT JOIN, and false for INNER JOIN.
+	left bool
+	on   string
+}
+
+// orderByExpr is an ORDER BY expression, evaluated for each sorted item.
+type orderByExpr struct {
+	query    string
+	evaluate func(item unstructured.Unstructured) (interface{}, error)
+}
+
+// requestedColumns are the columns projected from the items of queries selecting fields.
+type requestedColumns struct {
+	// columns are the selected columns, followed by hidden columns used only for sorting.
+	columns  []filter.Column
+	selected int
+}
+
 // NewSQLOptions provides an instance of SQLOptions with default values initialized
 func initializeDefaults(o *SQLOptions) {
-	o.defaultAliases = defaultAliases
-	o.defaultTableFields = defaultTableFields
+	// Queries add their own aliases and fields, copy the defaults so that
+	// statements of a script do not use the aliases of other statements.
+	o.defaultAliases = map[string]string{}
+	for k, v := range defaultAliases {
+		o.defaultAliases[k] = v
+	}
+	o.defaultTableFields = printers.TableFieldsMap{}
+	for k, v := range defaultTableFields {
+		o.defaultTableFields[k] = v
+	}
 	o.orderByFields = []printers.OrderByField{}
 	o.limit = 0 // Default to no limit
+	o.offset = 0
 }
 
 // checkColumnName checks if a column name has an alias.
 func (o *SQLOptions) checkColumnName(s string) (string, error) {
+	// Check for columns of named queries selecting fields.
+	if o.rowTitles[s] {
+		return s, nil
+	}
+
 	// Check for aliases.
 	if v, ok := o.defaultAliases[s]; ok {
 		return v, nil
 	}
 
+	// Check for values of subqueries, bound when the subqueries are run.
+	if subquery, ok := o.requestedSubqueries[s]; ok && subquery.literal != "" {
+		return subquery.literal, nil
+	}
+
+	// Check for aliases of table qualified names, e.g. "p.name" in a join, or "pvc.name"
+	// in a subquery referring to the outer query.
+	i := strings.Index(s, ".")
+	if i > 0 && ((o.requestedJoin != nil && o.isTableAlias(s[:i])) || o.isOuterField(s)) {
+		if v, ok := o.defaultAliases[s[i+1:]]; ok {
+			return s[:i+1] + v, nil
+		}
+		return s, nil
+	}
+
+	// Fields of queries that do not join tables are not qualified, e.g. "p.name" is "name"
+	// in a query listing pods as p.
+	if i > 0 && o.requestedJoin == nil && o.isTableAlias(s[:i]) {
+		if len(o.requestedResources) > 1 {
+			return "", fmt.Errorf("table qualified field %s is not supported when listing more than one resource", s)
+		}
+		return o.checkColumnName(s[i+1:])
+	}
+
 	// If not found in alias table, return the column name unchanged.
 	return s, nil
 }
+
+// isTableAlias checks if a name is the alias of a requested table.
+func (o *SQLOptions) isTableAlias(s string) bool {
+	if o.requestedJoin != nil && o.requestedJoin.resource.alias == s {
+		return true
+	}
+	for _, r := range o.requestedResources {
+		if r.alias == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isOuterField checks if a field name of a subquery is qualified by a table name
+// of the outer query, e.g. "pvc.name".
+func (o *SQLOptions) isOuterField(s string) bool {
+	i := strings.Index(s, ".")
+	if i <= 0 || o.outer == nil || o.isTableAlias(s[:i]) {
+		return false
+	}
+	return o.outer.isTableAlias(s[:i])
+}
+
+// columnIndex returns the index of a column by title, or -1 if not found.
+func (c *requestedColumns) columnIndex(title string) int {
+	for i, column := range c.columns {
+		if column.Title == title {
+			return i
+		}
+	}
+	return -1
+}
+
+// orderByColumn returns the title of the column to sort by, sorting by a field that is not
+// selected adds a hidden column, except in DISTINCT queries where rows must be sorted by
+// selected fields.
+func (c *requestedColumns) orderByColumn(title, name string, distinct bool) (string, error) {
+	if i := c.columnIndex(title); i >= 0 && i < c.selected {
+		return title, nil
+	}
+	for _, column := range c.columns {
+		if column.Query == name {
+			return column.Title, nil
+		}
+	}
+
+	if distinct {
+		return "", fmt.Errorf("ORDER BY field of SELECT DISTINCT query must be a selected field: %s", title)
+	}
+
+	column := filter.Column{
+		Title: fmt.Sprintf("_orderby%d", len(c.columns)),
+		Key:   -1,
+		Query: name,
+	}
+	c.columns = append(c.columns, column)
+	return column.Title, nil
+}
+
+// visibleRows returns rows without the hidden columns.
+func (c *requestedColumns) visibleRows(rows []unstructured.Unstructured) []unstructured.Unstructured {
+	if c.selected == len(c.columns) {
+		return rows
+	}
+
+	visible := make([]unstructured.Unstructured, len(rows))
+	for i, row := range rows {
+		object := map[string]interface{}{}
+		for key, value := range row.Object {
+			object[key] = value
+		}
+		for _, column := range c.columns[c.selected:] {
+			delete(object, column.Title)
+		}
+		visible[i] = unstructured.Unstructured{Object: object}
+	}
+	return visible
+}
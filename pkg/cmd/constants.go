@@ -40,6 +40,15 @@ var (
   # List first 5 pods ordered by creation time in descending order (newest first).
   kubectl sql "select * from pods order by created desc limit 5"
 
+  # List pods of a namespace created after a date, using query parameters.
+  kubectl sql "select * from */pods where namespace = :ns and created > :since" --param ns=prod --param since=2025-01-01
+
+  # Print how a query is run, without listing any objects.
+  kubectl sql "explain select name from */pods where labels.app = 'web'"
+
+  # Run the statements of a query script, and continue after a failing statement.
+  kubectl sql -f queries.sql --continue-on-error
+
   # Print this help message.
   kubectl sql help`
 
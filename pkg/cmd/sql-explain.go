@@ -0,0 +1,363 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/yaacov/tree-search-language/v6/pkg/tsl"
+	"github.com/yaacov/tree-search-language/v6/pkg/walkers/ident"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+
+	"github.com/yaacov/kubectl-sql/pkg/client"
+	"github.com/yaacov/kubectl-sql/pkg/filter"
+	"github.com/yaacov/kubectl-sql/pkg/printers"
+)
+
+// explainIndent is the indentation of nested parts of a query plan.
+const explainIndent = "  "
+
+// Explain prints how the query is run, the resources it lists and their namespaces, the resolved
+// aliases and search trees, the conditions the server can check, and the ordering and paging of
+// the result rows. Resources are looked up using the discovery API, no objects are listed.
+func (o *SQLOptions) Explain(config *rest.Config) error {
+	return o.explainPlan(o.Out, config, "")
+}
+
+// explainPlan prints the plan of a query, nested queries are printed indented.
+func (o *SQLOptions) explainPlan(w io.Writer, config *rest.Config, indent string) error {
+	if o.requestedUnion != nil {
+		for i, q := range o.requestedUnion.queries {
+			switch {
+			case i == 0:
+				fmt.Fprintf(w, "%sQUERY:\n", indent)
+			case o.requestedUnion.all[i]:
+				fmt.Fprintf(w, "%sUNION ALL:\n", indent)
+			default:
+				fmt.Fprintf(w, "%sUNION:\n", indent)
+			}
+			if err := q.explainPlan(w, config, indent+explainIndent); err != nil {
+				return err
+			}
+		}
+
+		o.explainWindow(w, indent)
+		return nil
+	}
+
+	for _, r := range o.requestedResources {
+		if err := o.explainResource(w, config, indent, "FROM", r); err != nil {
+			return err
+		}
+	}
+	if j := o.requestedJoin; j != nil {
+		clause := "JOIN"
+		if j.left {
+			clause = "LEFT JOIN"
+		}
+		if err := o.explainResource(w, config, indent, clause, j.resource); err != nil {
+			return err
+		}
+		if err := o.explainTree(w, indent+explainIndent, "ON", j.on); err != nil {
+			return err
+		}
+	}
+
+	if err := o.explainAliases(w, indent); err != nil {
+		return err
+	}
+	o.explainCalls(w, indent)
+
+	if err := o.explainTree(w, indent, "WHERE", o.requestedQuery); err != nil {
+		return err
+	}
+	if err := o.explainSubqueries(w, config, indent); err != nil {
+		return err
+	}
+
+	if c := o.requestedColumns; c != nil {
+		fmt.Fprintf(w, "%sPROJECT: %s\n", indent, explainColumns(c.columns[:c.selected]))
+	}
+	if g := o.requestedGroupBy; g != nil {
+		fmt.Fprintf(w, "%sGROUP BY: %s\n", indent, strings.Join(g.keys, ", "))
+		fmt.Fprintf(w, "%sAGGREGATE: %s\n", indent, explainColumns(g.columns[:g.selected]))
+		if err := o.explainTree(w, indent, "HAVING", g.having); err != nil {
+			return err
+		}
+	}
+	if o.distinct {
+		fmt.Fprintf(w, "%sDISTINCT: %s\n", indent, strings.Join(o.selectedTitles(), ", "))
+	}
+
+	o.explainWindow(w, indent)
+	if n := o.listLimit(); n > 0 {
+		fmt.Fprintf(w, "%sEARLY STOP: listing stops after %d matching items\n", indent, n)
+	}
+	return nil
+}
+
+// explainResource prints a resource of the FROM or JOIN clauses, its group version resource
+// and the namespaces listed, or the plan of a named query of the WITH clause.
+func (o *SQLOptions) explainResource(w io.Writer, config *rest.Config, indent string, clause string, r requestedResource) error {
+	fmt.Fprintf(w, "%s%s: %s AS %s\n", indent, clause, r.name, r.alias)
+	indent += explainIndent
+
+	if r.cte != nil {
+		fmt.Fprintf(w, "%sWITH QUERY:\n", indent)
+		return r.cte.options.explainPlan(w, config, indent+explainIndent)
+	}
+
+	c := client.Config{
+		Config:    config,
+		Namespace: r.namespace,
+		Discovery: o.discoveryClient,
+	}
+	gvr, namespaced, err := c.Resolve(r.name)
+	if err != nil {
+		fmt.Fprintf(w, "%sRESOURCE: unresolved, %v\n", indent, err)
+		return nil
+	}
+
+	group := gvr.Group
+	if group == "" {
+		group = "core"
+	}
+	fmt.Fprintf(w, "%sRESOURCE: %s/%s/%s\n", indent, group, gvr.Version, gvr.Resource)
+
+	switch {
+	case !namespaced:
+		fmt.Fprintf(w, "%sNAMESPACE: cluster scoped\n", indent)
+	case r.namespace == "" || r.namespace == "*":
+		fmt.Fprintf(w, "%sNAMESPACE: all namespaces\n", indent)
+	case client.IsNamespacePattern(r.namespace):
+		fmt.Fprintf(w, "%sNAMESPACE: namespaces matching %s\n", indent, r.namespace)
+	default:
+		fmt.Fprintf(w, "%sNAMESPACE: %s\n", indent, r.namespace)
+	}
+
+	return o.explainSelectors(w, indent, gvr)
+}
+
+// explainAliases prints the identifiers of the query replaced by aliases, e.g. phase -> status.phase.
+func (o *SQLOptions) explainAliases(w io.Writer, indent string) error {
+	queries := []string{o.requestedQuery}
+	if o.requestedJoin != nil {
+		queries = append(queries, o.requestedJoin.on)
+	}
+	for _, name := range sortedKeys(o.requestedCalls) {
+		queries = append(queries, o.requestedCalls[name].Args...)
+	}
+
+	aliases := []string{}
+	seen := map[string]bool{}
+	for _, q := range queries {
+		if q == "" {
+			continue
+		}
+
+		tree, err := tsl.ParseTSL(q)
+		if err != nil {
+			return err
+		}
+		_, err = ident.Walk(tree, func(s string) (string, error) {
+			v, err := o.checkColumnName(s)
+			if err == nil && v != s && !seen[s] {
+				seen[s] = true
+				aliases = append(aliases, s+" -> "+v)
+			}
+			return v, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(aliases) > 0 {
+		fmt.Fprintf(w, "%sALIASES: %s\n", indent, strings.Join(aliases, ", "))
+	}
+	return nil
+}
+
+// explainCalls prints the function calls replaced by placeholder identifiers in the search trees.
+func (o *SQLOptions) explainCalls(w io.Writer, indent string) {
+	for _, name := range sortedKeys(o.requestedCalls) {
+		call := o.requestedCalls[name]
+		fmt.Fprintf(w, "%sCALL: %s = %s(%s)\n", indent, name, call.Function, strings.Join(call.Args, ", "))
+	}
+}
+
+// explainSelectors prints the conditions of the WHERE clause the server checks when listing
+// the items of a resource.
+func (o *SQLOptions) explainSelectors(w io.Writer, indent string, gvr schema.GroupVersionResource) error {
+	requirements, err := o.serverRequirements()
+	if err != nil {
+		return err
+	}
+
+	labelSelector, fieldSelector := filter.Selectors(requirements)
+	fieldSelector = client.SelectFields(fieldSelector, gvr)
+	if labelSelector == "" && fieldSelector == "" {
+		fmt.Fprintf(w, "%sSERVER SIDE: none, items are filtered by the client\n", indent)
+		return nil
+	}
+	if labelSelector != "" {
+		fmt.Fprintf(w, "%sLABEL SELECTOR: %s\n", indent, labelSelector)
+	}
+	if fieldSelector != "" {
+		fmt.Fprintf(w, "%sFIELD SELECTOR: %s\n", indent, fieldSelector)
+	}
+	return nil
+}
+
+// explainSubqueries prints the plans of the subqueries of the WHERE clause.
+func (o *SQLOptions) explainSubqueries(w io.Writer, config *rest.Config, indent string) error {
+	names := make([]string, 0, len(o.requestedSubqueries))
+	for name := range o.requestedSubqueries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := o.requestedSubqueries[name]
+		run := "run once"
+		if s.correlated {
+			run = "run for each item"
+		}
+		fmt.Fprintf(w, "%sSUBQUERY: %s, %s\n", indent, name, run)
+		if err := s.options.explainPlan(w, config, indent+explainIndent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// explainWindow prints the ordering and paging of the result rows.
+func (o *SQLOptions) explainWindow(w io.Writer, indent string) {
+	if len(o.orderByFields) > 0 {
+		fields := make([]string, len(o.orderByFields))
+		for i, field := range o.orderByFields {
+			fields[i] = o.orderByName(field.Name)
+			switch field.Collation {
+			case printers.CollateNoCase:
+				fields[i] += " COLLATE nocase"
+			case printers.CollateNatural:
+				fields[i] += " COLLATE natural"
+			}
+			if field.Descending {
+				fields[i] += " DESC"
+			}
+			switch field.Nulls {
+			case printers.NullsFirst:
+				fields[i] += " NULLS FIRST"
+			case printers.NullsLast:
+				fields[i] += " NULLS LAST"
+			}
+		}
+		fmt.Fprintf(w, "%sORDER BY: %s\n", indent, strings.Join(fields, ", "))
+	}
+	if o.limit > 0 {
+		fmt.Fprintf(w, "%sLIMIT: %d\n", indent, o.limit)
+	}
+	if o.offset > 0 {
+		fmt.Fprintf(w, "%sOFFSET: %d\n", indent, o.offset)
+	}
+}
+
+// explainTree prints the search tree of a query after aliases are replaced.
+func (o *SQLOptions) explainTree(w io.Writer, indent string, clause string, query string) error {
+	if query == "" {
+		return nil
+	}
+
+	f := o.filterConfig(query)
+	tree, err := f.Tree()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%s%s:\n", indent, clause)
+	writeTree(w, indent+explainIndent, tree)
+	return nil
+}
+
+// orderByName returns the field or expression of a sort key, hidden columns used
+// for sorting are named by the expression they evaluate.
+func (o *SQLOptions) orderByName(name string) string {
+	if e, ok := o.orderByExprs[name]; ok {
+		return e.query
+	}
+	if c := o.requestedColumns; c != nil {
+		for _, column := range c.columns[c.selected:] {
+			if column.Title == name {
+				return column.Query
+			}
+		}
+	}
+	return name
+}
+
+// explainColumns renders columns as their titles and the queries they evaluate, e.g. phase = status.phase
+// or pods = count(*).
+func explainColumns(columns []filter.Column) string {
+	s := make([]string, len(columns))
+	for i, column := range columns {
+		s[i] = column.Title
+		switch {
+		case column.Function != "":
+			arg := column.Query
+			if arg == "" {
+				arg = "*"
+			}
+			s[i] += " = " + column.Function + "(" + arg + ")"
+		case column.Query != "" && column.Query != column.Title:
+			s[i] += " = " + column.Query
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+// writeTree prints a search tree, one node per line, operands indented below their operator.
+func writeTree(w io.Writer, indent string, node *tsl.TSLNode) {
+	switch node.Type() {
+	case tsl.KindBinaryExpr, tsl.KindUnaryExpr:
+		op := node.Value().(tsl.TSLExpressionOp)
+		fmt.Fprintf(w, "%s%s\n", indent, op.Operator)
+		if op.Left != nil {
+			writeTree(w, indent+explainIndent, op.Left)
+		}
+		if op.Right != nil {
+			writeTree(w, indent+explainIndent, op.Right)
+		}
+	default:
+		fmt.Fprintf(w, "%s%s\n", indent, literalText(node))
+	}
+}
+
+// literalText renders an identifier or a literal of a search tree.
+func literalText(node *tsl.TSLNode) string {
+	switch node.Type() {
+	case tsl.KindStringLiteral:
+		return fmt.Sprintf("'%s'", node.Value())
+	case tsl.KindArrayLiteral:
+		values := node.Value().(tsl.TSLArrayLiteral).Values
+		s := make([]string, len(values))
+		for i, v := range values {
+			s[i] = literalText(v)
+		}
+		return "[" + strings.Join(s, ", ") + "]"
+	default:
+		return fmt.Sprintf("%v", node.Value())
+	}
+}
+
+// sortedKeys returns the keys of a map of function calls in order.
+func sortedKeys(calls map[string]filter.Call) []string {
+	keys := make([]string, 0, len(calls))
+	for k := range calls {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,419 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yaacov/tree-search-language/v6/pkg/tsl"
+	"github.com/yaacov/tree-search-language/v6/pkg/walkers/ident"
+	"github.com/yaacov/tree-search-language/v6/pkg/walkers/semantics"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/yaacov/kubectl-sql/pkg/eval"
+	"github.com/yaacov/kubectl-sql/pkg/filter"
+	"github.com/yaacov/kubectl-sql/pkg/printers"
+	"github.com/yaacov/kubectl-sql/pkg/query"
+)
+
+// requestedGroupBy is the aggregation of an aggregated query.
+type requestedGroupBy struct {
+	// keys are the GROUP BY expressions.
+	keys []string
+	// keyExprs are the GROUP BY expressions as written in the query.
+	keyExprs []string
+	// columns are the selected columns, followed by hidden columns used only by the HAVING clause.
+	columns  []filter.Column
+	selected int
+
+	having string
+	// havingColumns maps the identifiers of the HAVING clause to column titles.
+	havingColumns map[string]string
+}
+
+// tslExpr renders an expression as TSL, calls of built-in functions are replaced by
+// placeholder identifiers, other function calls TSL can not evaluate are rejected.
+func (o *SQLOptions) tslExpr(e *query.Expr) (string, error) {
+	return e.TSL(o.callExpr)
+}
+
+// callExpr renders a function call or CASE expression as TSL.
+func (o *SQLOptions) callExpr(c *query.Call) (string, error) {
+	if c.Case != nil {
+		return o.caseExpr(c.Case)
+	}
+	if c.Subquery != nil {
+		return "", fmt.Errorf("subqueries are only supported in the WHERE clause: %s", c)
+	}
+
+	// TSL implements sum of array values as an operator, e.g. "sum (spec.containers[*].ports[*].containerPort)".
+	if strings.EqualFold(c.Name, "sum") && len(c.Args) == 1 {
+		arg, err := o.tslExpr(c.Args[0])
+		if err != nil {
+			return "", err
+		}
+		return "sum ( " + arg + " )", nil
+	}
+
+	if eval.IsAggregate(c.Name) {
+		return "", fmt.Errorf("aggregate function not allowed here: %s", c)
+	}
+	if err := eval.CheckFunction(c.Name, len(c.Args)); err != nil {
+		return "", err
+	}
+
+	// Replace the call with a placeholder identifier evaluated by the filter.
+	call := filter.Call{Function: c.Name}
+	for _, a := range c.Args {
+		arg, err := o.tslExpr(a)
+		if err != nil {
+			return "", err
+		}
+		call.Args = append(call.Args, arg)
+	}
+
+	return o.bindCall(call), nil
+}
+
+// caseExpr replaces a CASE expression with a placeholder identifier evaluated by the filter.
+func (o *SQLOptions) caseExpr(c *query.Case) (string, error) {
+	operand, err := o.tslExpr(c.Operand)
+	if err != nil {
+		return "", err
+	}
+
+	call := filter.Call{Function: filter.CaseFunction}
+	for _, w := range c.Whens {
+		cond, err := o.tslExpr(w.Cond)
+		if err != nil {
+			return "", err
+		}
+		result, err := o.tslExpr(w.Result)
+		if err != nil {
+			return "", err
+		}
+
+		// Simple CASE expressions compare the operand with the WHEN values.
+		if c.Operand != nil {
+			cond = "( " + operand + " ) = ( " + cond + " )"
+		}
+		call.Args = append(call.Args, cond, result)
+	}
+
+	if c.Else != nil {
+		result, err := o.tslExpr(c.Else)
+		if err != nil {
+			return "", err
+		}
+		call.Args = append(call.Args, result)
+	}
+
+	return o.bindCall(call), nil
+}
+
+// bindCall registers a call evaluated by the filter, and returns its placeholder identifier.
+func (o *SQLOptions) bindCall(call filter.Call) string {
+	if o.requestedCalls == nil {
+		o.requestedCalls = map[string]filter.Call{}
+	}
+	name := fmt.Sprintf("_call%d", len(o.requestedCalls))
+	o.requestedCalls[name] = call
+	return name
+}
+
+// aggregateCall returns the aggregate function call if an expression is one.
+func aggregateCall(e *query.Expr) (*query.Call, bool) {
+	c, ok := e.Call()
+	if !ok || !eval.IsAggregate(c.Name) {
+		return nil, false
+	}
+	return c, true
+}
+
+// isAggregated checks if a query aggregates items, a query is aggregated if it has
+// a GROUP BY or HAVING clause, or if all selected fields are aggregate functions.
+func isAggregated(stmt *query.Statement) bool {
+	if len(stmt.GroupBy) > 0 || stmt.Having != nil {
+		return true
+	}
+	if len(stmt.Fields) == 0 {
+		return false
+	}
+
+	for _, field := range stmt.Fields {
+		if _, ok := aggregateCall(field.Expr); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// parseGroupBy extracts and validates the GROUP BY clause and the fields of an aggregated query
+func (o *SQLOptions) parseGroupBy(stmt *query.Statement) error {
+	if stmt.Fields == nil {
+		return fmt.Errorf("SELECT * is not allowed with GROUP BY, select grouped fields and aggregate functions")
+	}
+
+	g := &requestedGroupBy{}
+	for _, e := range stmt.GroupBy {
+		// GROUP BY may refer to a selected expression by its alias, e.g. GROUP BY health.
+		if name, ok := e.Identifier(); ok {
+			for _, field := range stmt.Fields {
+				if field.Alias == name {
+					e = field.Expr
+					break
+				}
+			}
+		}
+
+		if name, ok := e.Identifier(); ok && !isValidFieldIdentifier(name) {
+			return fmt.Errorf("invalid GROUP BY field: %s", e)
+		}
+		key, err := o.parseFieldExpr(e)
+		if err != nil {
+			return err
+		}
+		g.keys = append(g.keys, key)
+		g.keyExprs = append(g.keyExprs, e.String())
+	}
+
+	tableFields := make([]printers.TableField, 0, len(stmt.Fields))
+	for _, field := range stmt.Fields {
+		column, err := o.parseGroupColumn(g, field)
+		if err != nil {
+			return err
+		}
+
+		g.columns = append(g.columns, column)
+		tableFields = append(tableFields, printers.TableField{
+			Name:  column.Title,
+			Title: column.Title,
+		})
+	}
+
+	g.selected = len(g.columns)
+	o.requestedGroupBy = g
+	o.defaultTableFields[printers.SelectedFields] = tableFields
+	return nil
+}
+
+// parseGroupColumn validates a field of an aggregated query, fields must be
+// GROUP BY fields or aggregate functions.
+func (o *SQLOptions) parseGroupColumn(g *requestedGroupBy, field query.Field) (filter.Column, error) {
+	column := filter.Column{Title: field.Alias, Key: -1}
+	if column.Title == "" {
+		column.Title = field.Expr.String()
+	} else if !isValidFieldIdentifier(column.Title) {
+		return column, fmt.Errorf("invalid field identifier after AS: %s", column.Title)
+	}
+
+	if c, ok := aggregateCall(field.Expr); ok {
+		return o.aggregateColumn(column.Title, c)
+	}
+
+	if name, ok := field.Expr.Identifier(); ok {
+		name, err := o.checkColumnName(name)
+		if err != nil {
+			return column, err
+		}
+
+		if column.Key = g.keyIndex(name); column.Key >= 0 {
+			return column, nil
+		}
+	}
+
+	// Expressions match GROUP BY expressions written the same way.
+	for i, e := range g.keyExprs {
+		if e == field.Expr.String() {
+			column.Key = i
+			return column, nil
+		}
+	}
+
+	return column, fmt.Errorf("field must appear in the GROUP BY clause or be used in an aggregate function: %s", field.Expr)
+}
+
+// aggregateColumn validates an aggregate function call and returns its column.
+func (o *SQLOptions) aggregateColumn(title string, c *query.Call) (filter.Column, error) {
+	column := filter.Column{Title: title, Key: -1, Function: strings.ToLower(c.Name)}
+
+	switch {
+	case c.Star && column.Function != "count":
+		return column, fmt.Errorf("%s(*) is not supported, use %s(field)", c.Name, c.Name)
+	case c.Star:
+		return column, nil
+	case len(c.Args) != 1:
+		return column, fmt.Errorf("aggregate function %s expects one argument", c.Name)
+	}
+
+	arg, err := o.tslExpr(c.Args[0])
+	if err != nil {
+		return column, err
+	}
+	column.Query = arg
+	return column, nil
+}
+
+// keyIndex returns the index of a resolved GROUP BY field, or -1 if not found.
+func (g *requestedGroupBy) keyIndex(name string) int {
+	for i, key := range g.keys {
+		if key == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// hiddenColumn returns the title of a column with the same value as column,
+// adding column to the hidden columns if no such column exists.
+func (g *requestedGroupBy) hiddenColumn(column filter.Column) string {
+	for _, c := range g.columns {
+		if c.Key == column.Key && c.Function == column.Function && c.Query == column.Query {
+			return c.Title
+		}
+	}
+
+	column.Title = fmt.Sprintf("_having%d", len(g.columns))
+	g.columns = append(g.columns, column)
+	return column.Title
+}
+
+// parseHaving extracts and validates the HAVING clause, aggregate functions and
+// fields of the clause are bound to columns of the aggregated rows.
+func (o *SQLOptions) parseHaving(e *query.Expr) error {
+	if e == nil {
+		return nil
+	}
+	g := o.requestedGroupBy
+	g.havingColumns = map[string]string{}
+
+	having, err := e.TSL(func(c *query.Call) (string, error) {
+		if c.Subquery != nil {
+			return "", fmt.Errorf("subqueries are only supported in the WHERE clause: %s", c)
+		}
+		if !eval.IsAggregate(c.Name) {
+			return "", fmt.Errorf("unknown function: %s", c.Name)
+		}
+
+		column, err := o.aggregateColumn("", c)
+		if err != nil {
+			return "", err
+		}
+
+		name := fmt.Sprintf("_aggregate%d", len(g.havingColumns))
+		g.havingColumns[name] = g.hiddenColumn(column)
+		return name, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Bind fields to selected columns or GROUP BY fields.
+	bind := func(s string) (string, error) {
+		if _, ok := g.havingColumns[s]; ok {
+			return s, nil
+		}
+
+		for _, c := range g.columns[:g.selected] {
+			if c.Title == s {
+				g.havingColumns[s] = c.Title
+				return s, nil
+			}
+		}
+
+		name, err := o.checkColumnName(s)
+		if err != nil {
+			return "", err
+		}
+		if key := g.keyIndex(name); key >= 0 {
+			g.havingColumns[s] = g.hiddenColumn(filter.Column{Key: key})
+			return s, nil
+		}
+
+		return "", fmt.Errorf("HAVING field must appear in the GROUP BY clause or be used in an aggregate function: %s", s)
+	}
+
+	tree, err := tsl.ParseTSL(having)
+	if err != nil {
+		return err
+	}
+	if _, err := ident.Walk(tree, bind); err != nil {
+		return err
+	}
+
+	g.having = having
+	return nil
+}
+
+// filterHaving filters aggregated rows using the HAVING clause, and removes hidden columns.
+func (g *requestedGroupBy) filterHaving(rows []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+	if g.having == "" {
+		return rows, nil
+	}
+
+	f := filter.Config{
+		CheckColumnName: func(s string) (string, error) {
+			return s, nil
+		},
+		Query: g.having,
+		EvalFunctionFactory: func(item unstructured.Unstructured) semantics.EvalFunc {
+			return func(key string) (interface{}, bool) {
+				return item.Object[g.havingColumns[key]], true
+			}
+		},
+	}
+
+	rows, err := f.Filter(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		for _, c := range g.columns[g.selected:] {
+			delete(row.Object, c.Title)
+		}
+	}
+	return rows, nil
+}
+
+// parseGroupOrderBy extracts and validates the ORDER BY clause of an aggregated query,
+// sort keys must be selected fields.
+func (o *SQLOptions) parseGroupOrderBy(items []query.OrderItem) error {
+	g := o.requestedGroupBy
+	orderByFields := make([]printers.OrderByField, 0, len(items))
+
+	for _, item := range items {
+		if item.Position > 0 {
+			if item.Position > g.selected {
+				return fmt.Errorf("ORDER BY position %d is not in the list of selected fields", item.Position)
+			}
+			orderByFields = append(orderByFields, orderByField(item, g.columns[item.Position-1].Title))
+			continue
+		}
+
+		title, ok := "", false
+		name, isIdentifier := item.Expr.Identifier()
+		if isIdentifier {
+			name, _ = o.checkColumnName(name)
+		}
+
+		for _, column := range g.columns[:g.selected] {
+			switch {
+			case strings.EqualFold(column.Title, item.Expr.String()):
+				title, ok = column.Title, true
+			case isIdentifier && column.Key >= 0 && g.keys[column.Key] == name:
+				title, ok = column.Title, true
+			}
+			if ok {
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("ORDER BY field of aggregated query must be a selected field: %s", item.Expr)
+		}
+
+		orderByFields = append(orderByFields, orderByField(item, title))
+	}
+
+	o.orderByFields = orderByFields
+	return nil
+}
@@ -20,8 +20,10 @@ Author: 2020 Yaacov Zamir <kobi.zamir@gmail.com>
 package cmd
 
 import (
+	"github.com/yaacov/tree-search-language/v6/pkg/walkers/semantics"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
+	"github.com/yaacov/kubectl-sql/pkg/eval"
 	"github.com/yaacov/kubectl-sql/pkg/printers"
 )
 
@@ -32,23 +34,26 @@ func (o *SQLOptions) Printer(items []unstructured.Unstructured) error {
 		return nil
 	}
 
-	p := printers.Config{
-		TableFields:   o.defaultTableFields,
-		OrderByFields: o.orderByFields,
-		Limit:         o.limit,
-		Out:           o.Out,
-		ErrOut:        o.ErrOut,
-		NoHeaders:     o.noHeaders,
+	p := o.printerConfig()
+
+	// Table printing sorts and pages items itself, other formats print the
+	// displayed window of sorted items without hidden columns used for sorting.
+	rows := items
+	if o.outputFormat == "yaml" || o.outputFormat == "json" || o.outputFormat == "name" {
+		rows = p.Window(items)
+		if o.requestedColumns != nil {
+			rows = o.requestedColumns.visibleRows(rows)
+		}
 	}
 
 	// Print out
 	switch o.outputFormat {
 	case "yaml":
-		return p.YAML(items)
+		return p.YAML(rows)
 	case "json":
-		return p.JSON(items)
+		return p.JSON(rows)
 	case "name":
-		return p.Name(items)
+		return p.Name(rows)
 	default:
 		err := p.Table(items)
 		if err != nil {
@@ -58,3 +63,82 @@ func (o *SQLOptions) Printer(items []unstructured.Unstructured) error {
 
 	return nil
 }
+
+// printerConfig returns the configuration used to sort, page and print result items.
+func (o *SQLOptions) printerConfig() printers.Config {
+	// Rows of queries selecting fields are keyed by column title.
+	evalFunctionFactory := o.evalFunctionFactory
+	if o.requestedGroupBy != nil || o.requestedColumns != nil || o.requestedUnion != nil {
+		evalFunctionFactory = eval.RowEvalFunctionFactory
+	} else if len(o.orderByExprs) > 0 {
+		evalFunctionFactory = o.orderByEvalFunctionFactory(evalFunctionFactory)
+	}
+
+	// Queries listing more than one resource print the kind of each row.
+	tableFields := o.defaultTableFields
+	if o.kindColumn {
+		tableFields = o.kindTableFields()
+	}
+
+	return printers.Config{
+		TableFields:         tableFields,
+		OrderByFields:       o.orderByFields,
+		Limit:               o.limit,
+		Offset:              o.offset,
+		Partial:             o.partial,
+		EvalFunctionFactory: evalFunctionFactory,
+		Out:                 o.Out,
+		ErrOut:              o.ErrOut,
+		NoHeaders:           o.noHeaders,
+	}
+}
+
+// orderByEvalFunctionFactory wraps an evaluation method factory, so that the placeholder
+// identifiers of ORDER BY expressions evaluate to the expression values.
+func (o *SQLOptions) orderByEvalFunctionFactory(evalFunctionFactory func(item unstructured.Unstructured) semantics.EvalFunc) func(item unstructured.Unstructured) semantics.EvalFunc {
+	if evalFunctionFactory == nil {
+		evalFunctionFactory = eval.EvalFunctionFactory
+	}
+
+	return func(item unstructured.Unstructured) semantics.EvalFunc {
+		evalItem := evalFunctionFactory(item)
+		return func(key string) (interface{}, bool) {
+			e, ok := o.orderByExprs[key]
+			if !ok {
+				return evalItem(key)
+			}
+
+			// Expressions that fail to evaluate are missing values.
+			value, err := e.evaluate(item)
+			if err != nil {
+				return nil, true
+			}
+			return value, true
+		}
+	}
+}
+
+// kindTableFields returns the table fields with a kind column before the selected fields,
+// or before the fields of the "other" template for queries selecting "*".
+func (o *SQLOptions) kindTableFields() printers.TableFieldsMap {
+	kind := printers.TableField{Title: "kind", Name: "kind"}
+	fields := o.defaultTableFields[printers.SelectedFields]
+	if fields == nil {
+		kind.Title = "KIND"
+		fields = o.defaultTableFields["other"]
+	}
+
+	// Queries selecting the kind print it where selected.
+	for _, field := range fields {
+		if field.Name == kind.Name {
+			return o.defaultTableFields
+		}
+	}
+
+	tableFields := printers.TableFieldsMap{}
+	for k, v := range o.defaultTableFields {
+		tableFields[k] = v
+	}
+	tableFields[printers.SelectedFields] = append([]printers.TableField{kind}, fields...)
+	return tableFields
+}
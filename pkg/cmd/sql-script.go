@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/yaacov/kubectl-sql/pkg/query"
+)
+
+// readScript reads the query script file, or the standard input if the file name is "-".
+func (o *SQLOptions) readScript() (string, error) {
+	var script []byte
+	var err error
+
+	if o.filename == "-" {
+		script, err = io.ReadAll(o.In)
+	} else {
+		script, err = os.ReadFile(o.filename)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(script), nil
+}
+
+// RunScript runs the statements of a query script in turn, each statement prints its own result,
+// results are separated by an empty line. Running stops at the first failing statement, unless
+// continue on error is set, failing statements still fail the script.
+func (o *SQLOptions) RunScript(config *rest.Config, script string) error {
+	statements := query.SplitStatements(script)
+	out := &sectionWriter{Writer: o.Out}
+
+	failed := 0
+	for i, statement := range statements {
+		out.separate = true
+		err := o.runStatement(config, statement, out)
+		if err == nil {
+			continue
+		}
+
+		err = fmt.Errorf("statement %d: %v", i+1, err)
+		if !o.continueOnError {
+			return err
+		}
+		fmt.Fprintf(o.ErrOut, "error: %v\n", err)
+		failed++
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d statements failed", failed, len(statements))
+	}
+	return nil
+}
+
+// runStatement runs one statement of a query script, printing its result to out, statements
+// do not share aliases, fields or named queries.
+func (o *SQLOptions) runStatement(config *rest.Config, statement string, out io.Writer) error {
+	s := o.queryOptions()
+	s.noHeaders = o.noHeaders
+	s.params = o.params
+	s.Out = out
+
+	if err := s.CompleteSQL(statement); err != nil {
+		return err
+	}
+	if len(s.requestedResources) == 0 {
+		return fmt.Errorf("invalid number of resources in query")
+	}
+
+	return s.Get(config)
+}
+
+// sectionWriter separates the results of the statements of a script by an empty line,
+// statements that print nothing are not separated.
+type sectionWriter struct {
+	io.Writer
+	// written is true once a statement printed its result, and separate is true
+	// until the running statement prints.
+	written  bool
+	separate bool
+}
+
+// Write prints the empty line before the first write of a statement result.
+func (w *sectionWriter) Write(p []byte) (int, error) {
+	if w.separate && w.written {
+		if _, err := fmt.Fprintln(w.Writer); err != nil {
+			return 0, err
+		}
+	}
+	w.separate = false
+	w.written = w.written || len(p) > 0
+	return w.Writer.Write(p)
+}
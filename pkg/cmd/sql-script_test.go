@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+)
+
+func TestRunScript(t *testing.T) {
+	s := newFakeServer(t, map[string][]map[string]interface{}{
+		"pods": {
+			fakePod("default", "web-0", "Running", nil),
+			fakePod("default", "web-1", "Pending", nil),
+		},
+	})
+	config := &rest.Config{Host: s.URL}
+
+	script := "SELECT name FROM */pods WHERE phase = 'Running';\n" +
+		"SELECT name FROM */pods WHERE phase ~ 'Pending';\n" +
+		"SELECT name FROM */pods WHERE phase = 'Pending';\n"
+
+	tests := []struct {
+		name            string
+		script          string
+		continueOnError bool
+		wantOut         string
+		wantErrOut      string
+		wantErr         string
+	}{
+		{
+			name:    "statements",
+			script:  "SELECT name FROM */pods WHERE phase = 'Running'; SELECT name FROM */pods WHERE phase = 'Pending'",
+			wantOut: "web-0\n\nweb-1\n",
+		},
+		{
+			name:    "stop at first error",
+			script:  script,
+			wantOut: "web-0\n",
+			wantErr: "statement 2: unexpected character '~'",
+		},
+		{
+			name:            "continue on error",
+			script:          script,
+			continueOnError: true,
+			wantOut:         "web-0\n\nweb-1\n",
+			wantErrOut:      "error: statement 2: unexpected character '~'",
+			wantErr:         "1 of 3 statements failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+			o := NewSQLOptions(genericclioptions.IOStreams{Out: out, ErrOut: errOut})
+			o.outputFormat = "name"
+			o.continueOnError = tt.continueOnError
+
+			err := o.RunScript(config, tt.script)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("RunScript() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)) {
+				t.Fatalf("RunScript() error = %v, want %q", err, tt.wantErr)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("RunScript() output = %q, want %q", out.String(), tt.wantOut)
+			}
+			if !strings.HasPrefix(errOut.String(), tt.wantErrOut) {
+				t.Errorf("RunScript() error output = %q, want %q", errOut.String(), tt.wantErrOut)
+			}
+		})
+	}
+}
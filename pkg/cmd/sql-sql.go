@@ -4,14 +4,17 @@ import (
 	"context"
 	"fmt"
 	"regexp"
-	"strconv"
 	"strings"
 
+	"github.com/yaacov/tree-search-language/v6/pkg/tsl"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/rest"
 
 	"github.com/yaacov/kubectl-sql/pkg/client"
+	"github.com/yaacov/kubectl-sql/pkg/eval"
 	"github.com/yaacov/kubectl-sql/pkg/filter"
 	"github.com/yaacov/kubectl-sql/pkg/printers"
+	"github.com/yaacov/kubectl-sql/pkg/query"
 )
 
 // isValidFieldIdentifier checks if a field name matches the allowed pattern
@@ -36,10 +39,19 @@ func isValidFieldIdentifier(field string) bool {
 		return match
 	}
 
+	// Check for quoted label and annotation keys, e.g. labels['app.kubernetes.io/name']
+	if strings.HasPrefix(field, "labels['") || strings.HasPrefix(field, "annotations['") {
+		// K8s label and annotation keys: an optional DNS prefix and a slash, followed by a name
+		keyPattern := `^(labels|annotations)\['([a-z0-9]([a-z0-9\-.]*[a-z0-9])?/)?[a-zA-Z0-9]([a-zA-Z0-9\-_.]*[a-zA-Z0-9])?'\]$`
+		match, _ := regexp.MatchString(keyPattern, field)
+		return match
+	}
+
 	// Matches patterns like:
 	// - simple: name, first_name, my.field
-	// - array access: items[0], my.array[123]
-	pattern := `^[a-zA-Z_]([a-zA-Z0-9_.]*(?:\[\d+\])?)*$`
+	// - array access: items[0], my.array[123], containers[*].image
+	// - quoted keys: metadata.labels['app.kubernetes.io/name']
+	pattern := `^[a-zA-Z_]([a-zA-Z0-9_.]*(?:\[(?:\d+|\*|'[^'\]]+')\])?)*$`
 	match, _ := regexp.MatchString(pattern, field)
 	return match
 }
@@ -47,369 +59,630 @@ func isValidFieldIdentifier(field string) bool {
 // isValidK8sResourceName checks if a resource name follows Kubernetes naming conventions
 func isValidK8sResourceName(resource string) bool {
 	// Matches lowercase words separated by dots or slashes
-	// Examples: pods, deployments, apps/v1/deployments
-	pattern := `^[a-z]+([a-z0-9-]*[a-z0-9])?(/[a-z0-9]+)*$`
+	// Examples: pods, deployments.apps, deployments.v1.apps, apps/v1/deployments
+	pattern := `^([a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*/[a-z0-9]+/)?[a-z]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`
 	match, _ := regexp.MatchString(pattern, resource)
 	return match
 }
 
-// isValidNamespace checks if a namespace name is valid according to Kubernetes naming conventions
-// or if it's the special "*" value for all namespaces
+// isValidNamespace checks if a namespace name is valid according to Kubernetes naming conventions,
+// if it's the special "*" value for all namespaces, or a namespace pattern, e.g. "team-*", "{prod,staging}"
+// or "!kube-*"
 func isValidNamespace(namespace string) bool {
 	// Special case for "all namespaces"
 	if namespace == "*" {
 		return true
 	}
 
+	if client.IsNamespacePattern(namespace) {
+		_, err := client.ParseNamespacePattern(namespace)
+		return err == nil
+	}
+
 	pattern := `^[a-z0-9]([a-z0-9\-]*[a-z0-9])?$`
 	match, _ := regexp.MatchString(pattern, namespace)
 	return match
 }
 
-// QueryType represents the type of SQL query
-type QueryType int
-
-const (
-	SimpleQuery QueryType = iota
-)
-
-// parseFields extracts and validates SELECT fields
-func (o *SQLOptions) parseFields(selectFields string) error {
-	if selectFields == "*" {
+// parseFields extracts and validates SELECT fields, selected fields and expressions are
+// projected into row columns
+func (o *SQLOptions) parseFields(fields []query.Field) error {
+	if fields == nil {
 		return nil
 	}
 
-	if len(strings.TrimSpace(selectFields)) == 0 {
-		return fmt.Errorf("SELECT clause cannot be empty")
-	}
-
-	fields := strings.Split(selectFields, ",")
 	tableFields := make([]printers.TableField, 0, len(fields))
+	c := &requestedColumns{}
 
 	for _, field := range fields {
-		field = strings.TrimSpace(field)
-
-		// Check for AS syntax
-		parts := strings.Split(strings.ToUpper(field), " AS ")
-		var name, title string
-
-		if len(parts) == 2 {
-			// We have an AS clause
-			name = strings.TrimSpace(field[:strings.Index(strings.ToUpper(field), " AS ")])
-			title = strings.TrimSpace(field[strings.Index(strings.ToUpper(field), " AS ")+4:])
+		// Expressions are titled by their source text, e.g. len(spec.containers).
+		title := field.Expr.String()
+		if _, ok := field.Expr.Identifier(); !ok {
+			title = field.Text
+		}
+		name, err := o.parseFieldExpr(field.Expr)
+		if err != nil {
+			return err
+		}
 
-			if !isValidFieldIdentifier(name) {
-				return fmt.Errorf("invalid field identifier before AS: %s", name)
-			}
+		// Append AS clause to default aliases
+		if field.Alias != "" {
+			title = field.Alias
 			if !isValidFieldIdentifier(title) {
 				return fmt.Errorf("invalid field identifier after AS: %s", title)
 			}
-		} else {
-			// No AS clause, use field as both name and title
-			if !isValidFieldIdentifier(field) {
-				return fmt.Errorf("invalid field identifier: %s", field)
+
+			o.defaultAliases[title] = name
+		}
+
+		// Check for columns with the same title and a different value
+		if i := c.columnIndex(title); i >= 0 {
+			if c.columns[i].Query != name {
+				return fmt.Errorf("duplicate column name: %s", title)
 			}
-			name = field
-			title = field
+			continue
 		}
 
-		// Append to table fields
+		// Append to table fields and projected columns
+		c.columns = append(c.columns, filter.Column{
+			Title: title,
+			Key:   -1,
+			Query: name,
+		})
 		tableFields = append(tableFields, printers.TableField{
-			Name:  name,
+			Name:  title,
 			Title: title,
 		})
-
-		// Append to default aliases
-		o.defaultAliases[title] = name
 	}
 
+	c.selected = len(c.columns)
+	o.requestedColumns = c
 	o.defaultTableFields[printers.SelectedFields] = tableFields
 	return nil
 }
 
-// parseResources validates and sets the requested resources
-func (o *SQLOptions) parseResources(resources []string, queryType QueryType) error {
-	for i, r := range resources {
-		r = strings.TrimSpace(r)
-
-		// Split resource on "/" to check for namespace
-		parts := strings.Split(r, "/")
-		var resourceName string
-
-		switch len(parts) {
-		case 1:
-			resourceName = parts[0]
-		case 2:
-			// Check for namespace validity
-			namespace := parts[0]
-			if !isValidNamespace(namespace) {
-				return fmt.Errorf("invalid namespace: %s", namespace)
-			}
+// parseFieldExpr validates a SELECT field, and returns the field name with aliases
+// resolved, or the TSL expression of computed fields in parentheses
+func (o *SQLOptions) parseFieldExpr(e *query.Expr) (string, error) {
+	expr, err := o.tslExpr(e)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tsl.ParseTSL(expr); err != nil {
+		return "", fmt.Errorf("invalid field expression: %s: %v", e, err)
+	}
 
-			// Set namespace options
-			o.namespace = namespace
-			resourceName = parts[1]
-		default:
-			return fmt.Errorf("invalid resource format: %s, expected [namespace/]resource or */resource for all namespaces", r)
-		}
+	// Resolve aliases, e.g. "phase" or "p.phase"
+	if name, ok := e.Identifier(); ok {
+		return o.checkColumnName(name)
+	}
 
-		if !isValidK8sResourceName(resourceName) {
-			return fmt.Errorf("invalid resource name: %s", resourceName)
-		}
+	// Use parentheses so that the expression can replace an alias in other expressions.
+	return "( " + expr + " )", nil
+}
 
-		resources[i] = resourceName
+// parseResource validates a resource of the FROM or JOIN clauses
+func (o *SQLOptions) parseResource(t query.TableRef) (requestedResource, error) {
+	r := strings.TrimSpace(t.Resource)
+	resource := requestedResource{namespace: o.namespace}
+
+	// Check for named queries of the WITH clause
+	if cte, ok := o.lookupCTE(r); ok {
+		resource.name = r
+		resource.cte = cte
+		resource.alias = t.Alias
+		if resource.alias == "" {
+			resource.alias = r
+		}
+		return resource, nil
 	}
 
-	if len(resources) != 1 {
-		return fmt.Errorf("exactly one resource must be specified")
-	}
+	// Split resource on "/" to check for namespace, resources may be
+	// qualified by group and version, e.g. "ns/apps/v1/deployments"
+	parts := strings.Split(r, "/")
 
-	o.requestedResources = resources
-	return nil
-}
+	switch len(parts) {
+	case 1, 3:
+		resource.name = r
+	case 2, 4:
+		// Check for namespace validity
+		namespace := parts[0]
+		if !isValidNamespace(namespace) {
+			return resource, fmt.Errorf("invalid namespace: %s", namespace)
+		}
 
-// identifyQueryType determines the type of SQL query and returns relevant indices
-func (o *SQLOptions) identifyQueryType(query string) (QueryType, map[string]int, error) {
-	upperQuery := strings.ToUpper(query)
-	if !strings.HasPrefix(upperQuery, "SELECT") {
-		return SimpleQuery, nil, fmt.Errorf("query must start with SELECT")
+		// Set namespace options
+		resource.namespace = namespace
+		resource.name = strings.Join(parts[1:], "/")
+	default:
+		return resource, fmt.Errorf("invalid resource format: %s, expected [namespace/][group/version/]resource, */resource for all namespaces or pattern/resource, e.g. team-*/resource", r)
 	}
 
-	indices := map[string]int{
-		"SELECT":   0,
-		"FROM":     strings.Index(upperQuery, " FROM "),
-		"JOIN":     strings.Index(upperQuery, " JOIN "),
-		"ON":       strings.Index(upperQuery, " ON "),
-		"WHERE":    strings.Index(upperQuery, " WHERE "),
-		"ORDER BY": strings.Index(upperQuery, " ORDER BY "),
-		"LIMIT":    strings.Index(upperQuery, " LIMIT "),
+	if !isValidK8sResourceName(resource.name) {
+		return resource, fmt.Errorf("invalid resource name: %s", resource.name)
 	}
 
-	if indices["FROM"] == -1 {
-		return 0, nil, fmt.Errorf("missing FROM clause in query")
+	// Use the resource name without group and version as table alias if not set,
+	// e.g. "deployments" for "apps/v1/deployments" or "deployments.v1.apps"
+	resource.alias = t.Alias
+	if resource.alias == "" {
+		alias := resource.name[strings.LastIndex(resource.name, "/")+1:]
+		resource.alias = strings.SplitN(alias, ".", 2)[0]
 	}
 
-	return SimpleQuery, indices, nil
+	return resource, nil
 }
 
-// parseOrderBy extracts and validates the ORDER BY clause
-func (o *SQLOptions) parseOrderBy(query string, indices map[string]int) error {
-	if indices["ORDER BY"] == -1 {
-		return nil
+// parseResources validates and sets the requested resources
+func (o *SQLOptions) parseResources(tables []query.TableRef, joins []query.Join) error {
+	if len(joins) > 1 {
+		return fmt.Errorf("only one JOIN allowed in query")
 	}
-
-	orderByStart := indices["ORDER BY"] + 9
-	var orderByEnd int
-	if indices["LIMIT"] != -1 {
-		orderByEnd = indices["LIMIT"]
-	} else {
-		orderByEnd = len(query)
+	if len(joins) > 0 && len(tables) > 1 {
+		return fmt.Errorf("JOIN is not supported with more than one resource in FROM clause")
 	}
 
-	orderByStr := strings.TrimSpace(query[orderByStart:orderByEnd])
-	if orderByStr == "" {
-		return fmt.Errorf("ORDER BY clause cannot be empty")
-	}
+	o.requestedResources = make([]requestedResource, 0, len(tables))
+	for _, t := range tables {
+		resource, err := o.parseResource(t)
+		if err != nil {
+			return err
+		}
+		if o.isTableAlias(resource.alias) {
+			return fmt.Errorf("duplicate table name: %s, use an alias to list a resource twice", resource.alias)
+		}
 
-	fields := strings.Split(orderByStr, ",")
-	orderByFields := make([]printers.OrderByField, 0, len(fields))
+		o.requestedResources = append(o.requestedResources, resource)
+	}
 
-	for _, field := range fields {
-		field = strings.TrimSpace(field)
-		if field == "" {
-			continue
+	for _, j := range joins {
+		resource, err := o.parseResource(j.Table)
+		if err != nil {
+			return err
+		}
+		if o.isTableAlias(resource.alias) {
+			return fmt.Errorf("duplicate table name: %s, use an alias to join a resource with itself", resource.alias)
 		}
 
-		parts := strings.Fields(field)
-		if len(parts) == 0 {
-			continue
+		on, err := o.tslExpr(j.On)
+		if err != nil {
+			return err
 		}
 
-		fieldName := parts[0]
-		// Check for possible alias
-		if alias, err := o.checkColumnName(fieldName); err == nil {
-			fieldName = alias
+		o.requestedJoin = &requestedJoin{
+			resource: resource,
+			left:     j.Left,
+			on:       on,
 		}
+		o.evalFunctionFactory = eval.JoinedEvalFunctionFactory([]string{o.requestedResources[0].alias, resource.alias})
+	}
+
+	return nil
+}
 
-		orderBy := printers.OrderByField{
-			Name:       fieldName,
-			Descending: false,
+// parseOrderBy extracts and validates the ORDER BY clause
+func (o *SQLOptions) parseOrderBy(items []query.OrderItem) error {
+	orderByFields := make([]printers.OrderByField, 0, len(items))
+
+	for _, item := range items {
+		fieldName, ok := item.Expr.Identifier()
+		switch {
+		case item.Position > 0:
+			// Sort projected rows by the title of a selected column, e.g. ORDER BY 2
+			if o.requestedColumns == nil || item.Position > o.requestedColumns.selected {
+				return fmt.Errorf("ORDER BY position %d is not in the list of selected fields", item.Position)
+			}
+			orderByFields = append(orderByFields, orderByField(item, o.requestedColumns.columns[item.Position-1].Title))
+			continue
+		case ok:
+			// Check for possible alias
+			if alias, err := o.checkColumnName(fieldName); err == nil {
+				fieldName = alias
+			}
+		case o.requestedColumns != nil:
+			// Projected rows can be sorted by expressions
+			expr, err := o.parseFieldExpr(item.Expr)
+			if err != nil {
+				return err
+			}
+			fieldName = expr
+		default:
+			// Items of queries selecting "*" are sorted by expressions evaluated for each item
+			expr, err := o.parseOrderByExpr(item.Expr)
+			if err != nil {
+				return err
+			}
+			fieldName = expr
 		}
 
-		// Check for DESC/ASC modifier
-		if len(parts) > 1 && strings.ToUpper(parts[1]) == "DESC" {
-			orderBy.Descending = true
+		// Sort projected rows by column title
+		if o.requestedColumns != nil {
+			title, err := o.requestedColumns.orderByColumn(item.Expr.String(), fieldName, o.distinct)
+			if err != nil {
+				return err
+			}
+			fieldName = title
 		}
 
-		orderByFields = append(orderByFields, orderBy)
+		orderByFields = append(orderByFields, orderByField(item, fieldName))
 	}
 
 	o.orderByFields = orderByFields
 	return nil
 }
 
-// parseLimit extracts and validates the LIMIT clause
-func (o *SQLOptions) parseLimit(query string, indices map[string]int) error {
-	if indices["LIMIT"] == -1 {
-		return nil
+// parseOrderByExpr validates an ORDER BY expression of a query selecting "*", and returns
+// the placeholder identifier evaluated to the expression value when items are sorted
+func (o *SQLOptions) parseOrderByExpr(e *query.Expr) (string, error) {
+	expr, err := o.parseFieldExpr(e)
+	if err != nil {
+		return "", err
 	}
 
-	limitStart := indices["LIMIT"] + 6
-	limitStr := strings.TrimSpace(query[limitStart:])
+	f := o.filterConfig(expr)
+	evaluate, err := f.Evaluator()
+	if err != nil {
+		return "", fmt.Errorf("invalid ORDER BY expression: %s: %v", e, err)
+	}
 
-	// Check if there are other clauses after LIMIT
-	if space := strings.Index(limitStr, " "); space != -1 {
-		limitStr = limitStr[:space]
+	if o.orderByExprs == nil {
+		o.orderByExprs = map[string]orderByExpr{}
 	}
+	name := fmt.Sprintf("_orderby%d", len(o.orderByExprs))
+	o.orderByExprs[name] = orderByExpr{query: expr, evaluate: evaluate}
+	return name, nil
+}
 
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil {
-		return fmt.Errorf("invalid LIMIT value: %s", limitStr)
+// orderByField returns the sort key of an ORDER BY item, sorting by a field or column name
+func orderByField(item query.OrderItem, name string) printers.OrderByField {
+	field := printers.OrderByField{
+		Name:       name,
+		Descending: item.Descending,
 	}
 
-	if limit < 0 {
-		return fmt.Errorf("LIMIT cannot be negative: %d", limit)
+	switch item.Nulls {
+	case "FIRST":
+		field.Nulls = printers.NullsFirst
+	case "LAST":
+		field.Nulls = printers.NullsLast
 	}
 
-	o.limit = limit
-	return nil
+	switch item.Collation {
+	case "nocase":
+		field.Collation = printers.CollateNoCase
+	case "natural":
+		field.Collation = printers.CollateNatural
+	}
+
+	return field
 }
 
-// parseQueryParts extracts and validates different parts of the query
-func (o *SQLOptions) parseQueryParts(query string, indices map[string]int, queryType QueryType) error {
-	// Parse FROM resource (only one resource allowed)
-	var fromEnd int
-	if indices["WHERE"] != -1 {
-		fromEnd = indices["WHERE"]
-	} else if indices["ORDER BY"] != -1 {
-		fromEnd = indices["ORDER BY"]
-	} else if indices["LIMIT"] != -1 {
-		fromEnd = indices["LIMIT"]
-	} else {
-		fromEnd = len(query)
+// CompleteSQL parses SQL query into components
+func (o *SQLOptions) CompleteSQL(q string) error {
+	stmt, err := query.ParseWithParams(q, o.params)
+	if err != nil {
+		return err
 	}
+	o.explain = stmt.Explain
 
-	fromPart := strings.TrimSpace(query[indices["FROM"]+5 : fromEnd])
-	resources := strings.Split(fromPart, ",")
-	if len(resources) != 1 {
-		return fmt.Errorf("only one resource allowed in FROM clause")
-	}
+	return o.completeStatement(stmt)
+}
 
-	allResources := []string{resources[0]}
+// completeStatement sets the components of a parsed query
+func (o *SQLOptions) completeStatement(stmt *query.Statement) error {
+	var err error
 
-	if err := o.parseResources(allResources, queryType); err != nil {
+	if err := o.parseWith(stmt.With); err != nil {
 		return err
 	}
 
-	// Parse SELECT fields
-	selectFields := strings.TrimSpace(query[6:indices["FROM"]])
-	if err := o.parseFields(selectFields); err != nil {
+	if len(stmt.Unions) > 0 {
+		return o.parseUnion(stmt)
+	}
+
+	if err := o.parseResources(stmt.From, stmt.Joins); err != nil {
+		return err
+	}
+	if err := o.parseCTEResources(); err != nil {
 		return err
 	}
 
 	// Parse WHERE clause if present
-	if indices["WHERE"] != -1 {
-		whereStart := indices["WHERE"] + 6
-		var whereEnd int
-		if indices["ORDER BY"] != -1 {
-			whereEnd = indices["ORDER BY"]
-		} else if indices["LIMIT"] != -1 {
-			whereEnd = indices["LIMIT"]
-		} else {
-			whereEnd = len(query)
+	if o.requestedQuery, err = o.whereExpr(stmt.Where); err != nil {
+		return err
+	}
+
+	if stmt.Distinct && stmt.Fields == nil {
+		return fmt.Errorf("SELECT DISTINCT * is not supported, select the fields to compare")
+	}
+	o.distinct = stmt.Distinct
+	o.limit = stmt.Limit
+	o.offset = stmt.Offset
+
+	// Rows of queries listing more than one resource can be of different kinds.
+	o.kindColumn = len(o.requestedResources) > 1 && !stmt.Distinct && !isAggregated(stmt)
+
+	// Parse SELECT fields, GROUP BY and ORDER BY clauses of aggregated queries
+	if isAggregated(stmt) {
+		if err := o.parseGroupBy(stmt); err != nil {
+			return err
 		}
-		wherePart := strings.TrimSpace(query[whereStart:whereEnd])
-		if wherePart == "" {
-			return fmt.Errorf("WHERE clause cannot be empty")
+
+		if err := o.parseHaving(stmt.Having); err != nil {
+			return err
 		}
-		o.requestedQuery = wherePart
+
+		return o.parseGroupOrderBy(stmt.OrderBy)
 	}
 
-	// Parse ORDER BY clause if present
-	if err := o.parseOrderBy(query, indices); err != nil {
+	// Parse SELECT fields
+	if err := o.parseFields(stmt.Fields); err != nil {
 		return err
 	}
 
-	// Parse LIMIT clause if present
-	if err := o.parseLimit(query, indices); err != nil {
+	// Parse ORDER BY clause if present
+	return o.parseOrderBy(stmt.OrderBy)
+}
+
+// Get the resource list.
+func (o *SQLOptions) Get(config *rest.Config) error {
+	if o.explain {
+		return o.Explain(config)
+	}
+
+	rows, err := o.queryRows(context.Background(), config)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return o.Printer(rows)
 }
 
-// CompleteSQL parses SQL query into components
-func (o *SQLOptions) CompleteSQL(query string) error {
-	queryType, indices, err := o.identifyQueryType(query)
+// queryRows lists, filters and projects or aggregates the items of the requested resources
+// into the result rows of the query, rows are sorted and paged when printed.
+func (o *SQLOptions) queryRows(ctx context.Context, config *rest.Config) ([]unstructured.Unstructured, error) {
+	if o.requestedUnion != nil {
+		return o.unionRows(ctx, config)
+	}
+
+	if err := o.bindSubqueries(ctx, config); err != nil {
+		return nil, err
+	}
+
+	items, err := o.filteredItems(ctx, config)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := o.parseQueryParts(query, indices, queryType); err != nil {
-		return err
+	// Aggregated rows of queries matching no items have the kind of the requested resources.
+	if len(items) == 0 && o.requestedGroupBy != nil {
+		if o.resourceKind, err = o.requestedKind(config); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	return o.resultRows(items)
 }
 
-// Get the resource list.
-func (o *SQLOptions) Get(config *rest.Config) error {
-	c := client.Config{
-		Config:    config,
-		Namespace: o.namespace,
+// filteredItems lists the items of the requested resources matching the WHERE clause, items of
+// resources that are not joined are filtered chunk by chunk as they are listed.
+func (o *SQLOptions) filteredItems(ctx context.Context, config *rest.Config) ([]unstructured.Unstructured, error) {
+	if len(o.requestedQuery) == 0 {
+		return o.listItems(ctx, config, o.listLimit(), nil)
+	}
+
+	f := o.filterConfig(o.requestedQuery)
+	if o.requestedJoin != nil {
+		items, err := o.listItems(ctx, config, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+		return f.Filter(items)
 	}
 
-	if len(o.requestedQuery) > 0 {
-		return o.printFilteredResources(c)
+	match, err := f.Matcher()
+	if err != nil {
+		return nil, err
 	}
+	return o.listItems(ctx, config, o.listLimit(), func(items []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+		matched := []unstructured.Unstructured{}
+		for _, item := range items {
+			if match(item) {
+				matched = append(matched, item)
+			}
+		}
+		return matched, nil
+	})
+}
 
-	return o.printResources(c)
+// listLimit returns the number of matching items needed to print the rows of queries printing
+// the first rows of unsorted items, listing stops once enough items match, 0 for other queries.
+func (o *SQLOptions) listLimit() int {
+	if o.limit == 0 || len(o.orderByFields) > 0 || o.requestedGroupBy != nil || o.distinct || o.requestedJoin != nil {
+		return 0
+	}
+	return o.offset + o.limit
 }
 
-// printResources prints resources lists.
-func (o *SQLOptions) printResources(c client.Config) error {
-	ctx := context.Background()
+// filterConfig returns the configuration used to filter, project and aggregate items by query.
+func (o *SQLOptions) filterConfig(query string) filter.Config {
+	return filter.Config{
+		CheckColumnName:     o.checkColumnName,
+		Query:               query,
+		EvalFunctionFactory: o.evalFunctionFactory,
+		Calls:               o.requestedCalls,
+		Kind:                o.resourceKind,
+	}
+}
+
+// serverRequirements returns the conditions of the WHERE clause the server can check when
+// listing items, queries filtering joined items or rows of named queries have none.
+func (o *SQLOptions) serverRequirements() ([]filter.Requirement, error) {
+	if o.requestedQuery == "" || o.requestedJoin != nil {
+		return nil, nil
+	}
 	for _, r := range o.requestedResources {
-		list, err := c.List(ctx, r)
-		if err != nil {
-			return err
+		if r.cte != nil {
+			return nil, nil
 		}
+	}
 
-		err = o.Printer(list)
-		if err != nil {
-			return err
+	f := o.filterConfig(o.requestedQuery)
+	return f.Requirements()
+}
+
+// list lists the items of a requested resource, or returns the result items of a named query,
+// chunk is called for each chunk of listed items, and returns the items to keep, listing stops
+// once limit items are kept, 0 lists all items. The returned flag is true if listing stopped
+// with items left that may be kept.
+func (o *SQLOptions) list(ctx context.Context, config *rest.Config, r requestedResource, limit int, chunk func([]unstructured.Unstructured) ([]unstructured.Unstructured, error)) ([]unstructured.Unstructured, bool, error) {
+	if r.cte != nil {
+		items, err := r.cte.list(ctx, config)
+		if err == nil && chunk != nil {
+			items, err = chunk(items)
 		}
+		more := false
+		if err == nil && limit > 0 && len(items) > limit {
+			items, more = items[:limit], true
+		}
+		return items, more, err
 	}
 
-	return nil
+	// The server narrows the listed items by the labels and fields of the WHERE clause,
+	// items are still filtered by the query.
+	requirements, err := o.serverRequirements()
+	if err != nil {
+		return nil, false, err
+	}
+	labelSelector, fieldSelector := filter.Selectors(requirements)
+
+	c := client.Config{
+		Config:        config,
+		Namespace:     r.namespace,
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+		ChunkSize:     o.chunkSize,
+		Chunk:         chunk,
+		Limit:         limit,
+		Discovery:     o.discoveryClient,
+	}
+
+	return c.List(ctx, r.name)
 }
 
-// printFilteredResources prints filtered resource list.
-func (o *SQLOptions) printFilteredResources(c client.Config) error {
-	ctx := context.Background()
-	f := filter.Config{
-		CheckColumnName: o.checkColumnName,
-		Query:           o.requestedQuery,
+// resultRows projects or aggregates the items into the result rows of the query.
+func (o *SQLOptions) resultRows(items []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+	if o.requestedColumns != nil {
+		p := o.filterConfig("")
+
+		var err error
+		if items, err = p.Project(items, o.requestedColumns.columns); err != nil {
+			return nil, err
+		}
 	}
 
-	// Print resources lists.
-	for _, r := range o.requestedResources {
-		list, err := c.List(ctx, r)
+	if o.requestedGroupBy != nil {
+		g := o.filterConfig("")
+
+		rows, err := g.Group(items, o.requestedGroupBy.keys, o.requestedGroupBy.columns)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		// Filter items by query.
-		filteredList, err := f.Filter(list)
-		if err != nil {
-			return err
+		// Filter aggregated rows by the HAVING clause.
+		if items, err = o.requestedGroupBy.filterHaving(rows); err != nil {
+			return nil, err
 		}
+	}
+
+	// Remove duplicate rows of SELECT DISTINCT queries.
+	if o.distinct {
+		items = filter.Distinct(items, o.selectedTitles())
+	}
 
-		err = o.Printer(filteredList)
+	return items, nil
+}
+
+// requestedKind looks up the kind of the requested kubernetes resources, e.g. "Pod" for pods,
+// or a comma separated list of kinds, e.g. "Pod,Node" for joined resources.
+func (o *SQLOptions) requestedKind(config *rest.Config) (string, error) {
+	resources := append([]requestedResource{}, o.requestedResources...)
+	if o.requestedJoin != nil {
+		resources = append(resources, o.requestedJoin.resource)
+	}
+
+	kinds := []string{}
+	seen := map[string]bool{}
+	for _, r := range resources {
+		if r.cte != nil {
+			continue
+		}
+
+		c := client.Config{Config: config, Discovery: o.discoveryClient}
+		kind, err := c.Kind(r.name)
 		if err != nil {
-			return err
+			return "", err
+		}
+		if !seen[kind] || o.requestedJoin != nil {
+			seen[kind] = true
+			kinds = append(kinds, kind)
 		}
 	}
+	return strings.Join(kinds, ","), nil
+}
 
-	return nil
+// joinedItems lists the FROM and JOIN resources, and joins their items using the ON condition.
+func (o *SQLOptions) joinedItems(ctx context.Context, config *rest.Config) ([]unstructured.Unstructured, error) {
+	left := o.requestedResources[0]
+	right := o.requestedJoin.resource
+
+	leftList, _, err := o.list(ctx, config, left, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	rightList, _, err := o.list(ctx, config, right, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	j := o.filterConfig(o.requestedJoin.on)
+	return j.Join(leftList, rightList, left.alias, right.alias, o.requestedJoin.left)
+}
+
+// selectedTitles returns the titles of the selected columns.
+func (o *SQLOptions) selectedTitles() []string {
+	titles := []string{}
+	for _, field := range o.defaultTableFields[printers.SelectedFields] {
+		titles = append(titles, field.Name)
+	}
+	return titles
+}
+
+// listItems lists the items of the requested resources, joined if the query has a JOIN clause,
+// chunk is called for each chunk of listed items that are not joined, nil keeps all items, and
+// listing stops once limit items are kept, 0 lists all items.
+func (o *SQLOptions) listItems(ctx context.Context, config *rest.Config, limit int, chunk func([]unstructured.Unstructured) ([]unstructured.Unstructured, error)) ([]unstructured.Unstructured, error) {
+	if o.requestedJoin != nil {
+		return o.joinedItems(ctx, config)
+	}
+
+	items := []unstructured.Unstructured{}
+	o.partial = false
+	for i, r := range o.requestedResources {
+		remaining := 0
+		if limit > 0 {
+			remaining = limit - len(items)
+		}
+		list, more, err := o.list(ctx, config, r, remaining, chunk)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, list...)
+		if limit > 0 && len(items) >= limit {
+			// Items left in this resource, or in the resources not listed, may be kept.
+			o.partial = more || i < len(o.requestedResources)-1
+			break
+		}
+	}
+	return items, nil
 }
@@ -0,0 +1,462 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+)
+
+// fakeServer is an API server serving lists of core resources, in chunks when a limit is requested.
+type fakeServer struct {
+	*httptest.Server
+	// objects are the objects of each resource, keyed by resource name, e.g. pods.
+	objects map[string][]map[string]interface{}
+	// requests are the paths and queries of the list requests.
+	requests []string
+}
+
+// newFakeServer starts an API server serving the objects of core resources.
+func newFakeServer(t *testing.T, objects map[string][]map[string]interface{}) *fakeServer {
+	s := &fakeServer{objects: objects}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeServer) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/api":
+		fmt.Fprint(w, `{"kind":"APIVersions","versions":["v1"]}`)
+		return
+	case "/apis":
+		fmt.Fprint(w, `{"kind":"APIGroupList","groups":[]}`)
+		return
+	case "/api/v1":
+		resources := []string{}
+		for name := range s.objects {
+			kind := strings.TrimSuffix(name, "s")
+			resources = append(resources, fmt.Sprintf(`{"name":%q,"singularName":%q,"namespaced":true,"kind":%q,"verbs":["list"]}`, name, kind, strings.ToUpper(kind[:1])+kind[1:]))
+		}
+		fmt.Fprintf(w, `{"kind":"APIResourceList","groupVersion":"v1","resources":[%s]}`, strings.Join(resources, ","))
+		return
+	}
+
+	// List paths are /api/v1/<resource> or /api/v1/namespaces/<namespace>/<resource>.
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/")
+	namespace, resource := "", parts[0]
+	if len(parts) == 3 && parts[0] == "namespaces" {
+		namespace, resource = parts[1], parts[2]
+	}
+	objects, ok := s.objects[resource]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.requests = append(s.requests, r.URL.Path+"?"+r.URL.RawQuery)
+
+	items := []map[string]interface{}{}
+	for _, object := range objects {
+		item := unstructured.Unstructured{Object: object}
+		if namespace == "" || item.GetNamespace() == namespace {
+			items = append(items, object)
+		}
+	}
+
+	// Continue tokens are the index of the first item of the next chunk.
+	start, _ := strconv.Atoi(r.URL.Query().Get("continue"))
+	end := len(items)
+	next := ""
+	if limit, _ := strconv.Atoi(r.URL.Query().Get("limit")); limit > 0 && start+limit < end {
+		end = start + limit
+		next = strconv.Itoa(end)
+	}
+
+	b, _ := json.Marshal(map[string]interface{}{
+		"kind":       "List",
+		"apiVersion": "v1",
+		"metadata":   map[string]interface{}{"continue": next},
+		"items":      items[start:end],
+	})
+	w.Write(b)
+}
+
+// fakePod returns a pod object.
+func fakePod(namespace, name, phase string, labels map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace, "labels": labels},
+		"status":     map[string]interface{}{"phase": phase},
+	}
+}
+
+// queryRowNames runs a query and returns the names of its result rows, as printed in name format.
+func queryRowNames(t *testing.T, config *rest.Config, q string) ([]string, error) {
+	t.Helper()
+
+	out := &bytes.Buffer{}
+	o := NewSQLOptions(genericclioptions.IOStreams{Out: out, ErrOut: out})
+	o.outputFormat = "name"
+	if err := o.CompleteSQL(q); err != nil {
+		return nil, err
+	}
+	if err := o.Get(config); err != nil {
+		return nil, err
+	}
+	return strings.Fields(out.String()), nil
+}
+
+func TestQualifiedFields(t *testing.T) {
+	s := newFakeServer(t, map[string][]map[string]interface{}{
+		"pods": {
+			fakePod("default", "web-0", "Running", nil),
+			fakePod("default", "web-1", "Pending", nil),
+		},
+	})
+	config := &rest.Config{Host: s.URL}
+
+	tests := []struct {
+		name    string
+		query   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "unqualified", query: "SELECT name FROM */pods WHERE phase = 'Running'", want: []string{"web-0"}},
+		{name: "alias", query: "SELECT p.name AS name FROM */pods p WHERE p.phase = 'Running'", want: []string{"web-0"}},
+		{name: "table name", query: "SELECT name FROM */pods WHERE pods.status.phase = 'Pending' ORDER BY pods.name", want: []string{"web-1"}},
+		{name: "more than one resource", query: "SELECT name FROM */pods p, */pods q WHERE p.phase = 'Running'", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := queryRowNames(t, config, tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("query error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("query rows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEarlyStop(t *testing.T) {
+	pods := []map[string]interface{}{}
+	for i := 0; i < 10; i++ {
+		phase := "Running"
+		if i%2 == 1 {
+			phase = "Pending"
+		}
+		pods = append(pods, fakePod("default", fmt.Sprintf("pod-%d", i), phase, nil))
+	}
+
+	tests := []struct {
+		name         string
+		query        string
+		want         []string
+		wantRequests int
+		wantHeader   string
+	}{
+		{name: "limit", query: "SELECT name FROM */pods LIMIT 3", want: []string{"pod-0", "pod-1", "pod-2"}, wantRequests: 2, wantHeader: "KIND: Pod\tCOUNT: 3+"},
+		{name: "where", query: "SELECT name FROM */pods WHERE phase = 'Pending' LIMIT 2 OFFSET 1", want: []string{"pod-3", "pod-5"}, wantRequests: 3, wantHeader: "KIND: Pod\tCOUNT: 3+\tDISPLAYING: 2-3"},
+		{name: "limit in the last chunk", query: "SELECT name FROM */pods WHERE name in ['pod-8', 'pod-9'] LIMIT 1, 1", want: []string{"pod-9"}, wantRequests: 5, wantHeader: "KIND: Pod\tCOUNT: 2\tDISPLAYING: 2-2"},
+		{name: "less than limit", query: "SELECT name FROM */pods WHERE name = 'pod-9' LIMIT 2", want: []string{"pod-9"}, wantRequests: 5, wantHeader: "KIND: Pod\tCOUNT: 1"},
+		{name: "order by", query: "SELECT name FROM */pods ORDER BY name DESC LIMIT 1", want: []string{"pod-9"}, wantRequests: 5, wantHeader: "KIND: Pod\tCOUNT: 10\tDISPLAYING: 1"},
+		{name: "aggregate", query: "SELECT count(*) AS name FROM */pods LIMIT 1", want: []string{""}, wantRequests: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeServer(t, map[string][]map[string]interface{}{"pods": pods})
+			config := &rest.Config{Host: s.URL}
+
+			out := &bytes.Buffer{}
+			o := NewSQLOptions(genericclioptions.IOStreams{Out: out, ErrOut: out})
+			o.chunkSize = 2
+			if err := o.CompleteSQL(tt.query); err != nil {
+				t.Fatalf("CompleteSQL() error = %v", err)
+			}
+			rows, err := o.queryRows(context.Background(), config)
+			if err != nil {
+				t.Fatalf("queryRows() error = %v", err)
+			}
+			if len(s.requests) != tt.wantRequests {
+				t.Errorf("list requests = %d, want %d", len(s.requests), tt.wantRequests)
+			}
+
+			p := o.printerConfig()
+			got := []string{}
+			for _, row := range p.Window(rows) {
+				name, _ := row.Object["name"].(string)
+				got = append(got, name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("query rows = %v, want %v", got, tt.want)
+			}
+
+			if tt.wantHeader == "" {
+				return
+			}
+			if err := o.Printer(rows); err != nil {
+				t.Fatalf("Printer() error = %v", err)
+			}
+			if header := strings.SplitN(out.String(), "\n", 2)[0]; header != tt.wantHeader {
+				t.Errorf("table header = %q, want %q", header, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestEmptyAggregateKind(t *testing.T) {
+	s := newFakeServer(t, map[string][]map[string]interface{}{
+		"pods": {fakePod("default", "web-0", "Running", nil)},
+	})
+	config := &rest.Config{Host: s.URL}
+
+	out := &bytes.Buffer{}
+	o := NewSQLOptions(genericclioptions.IOStreams{Out: out, ErrOut: out})
+	if err := o.CompleteSQL("SELECT count(*) AS count FROM */pods WHERE phase = 'Failed'"); err != nil {
+		t.Fatalf("CompleteSQL() error = %v", err)
+	}
+	if err := o.Get(config); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	want := "KIND: Pod\tCOUNT: 1"
+	if header := strings.SplitN(out.String(), "\n", 2)[0]; header != want {
+		t.Errorf("table header = %q, want %q", header, want)
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	s := newFakeServer(t, map[string][]map[string]interface{}{
+		"pods": {
+			fakePod("default", "web-0", "Running", nil),
+			fakePod("test", "web-1", "Pending", nil),
+		},
+	})
+	config := &rest.Config{Host: s.URL}
+
+	tests := []struct {
+		name   string
+		query  string
+		format string
+		want   string
+	}{
+		{
+			name:   "table",
+			query:  "SELECT name, phase FROM */pods",
+			format: "table",
+			want:   "KIND: Pod\tCOUNT: 2\nname \tphase  \t\nweb-0\tRunning\t\nweb-1\tPending\t\n",
+		},
+		{name: "name", query: "SELECT * FROM */pods", format: "name", want: "web-0\nweb-1\n"},
+		{name: "name of selected fields", query: "SELECT namespace FROM */pods", format: "name", want: "web-0\nweb-1\n"},
+		{
+			name:   "json of selected fields",
+			query:  "SELECT namespace, phase FROM */pods",
+			format: "json",
+			want: "\n" + `{"Object":{"kind":"Pod","metadata":{"name":"web-0"},"namespace":"default","phase":"Running"}}` + "\n" +
+				"\n" + `{"Object":{"kind":"Pod","metadata":{"name":"web-1"},"namespace":"test","phase":"Pending"}}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			o := NewSQLOptions(genericclioptions.IOStreams{Out: out, ErrOut: out})
+			o.outputFormat = tt.format
+			if err := o.CompleteSQL(tt.query); err != nil {
+				t.Fatalf("CompleteSQL() error = %v", err)
+			}
+			if err := o.Get(config); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+// queryObjects runs a query and returns the objects of its result rows, as printed in json format.
+func queryObjects(t *testing.T, config *rest.Config, q string) ([]map[string]interface{}, error) {
+	t.Helper()
+
+	out := &bytes.Buffer{}
+	o := NewSQLOptions(genericclioptions.IOStreams{Out: out, ErrOut: out})
+	o.outputFormat = "json"
+	if err := o.CompleteSQL(q); err != nil {
+		return nil, err
+	}
+	if err := o.Get(config); err != nil {
+		return nil, err
+	}
+
+	objects := []map[string]interface{}{}
+	d := json.NewDecoder(out)
+	for d.More() {
+		row := struct{ Object map[string]interface{} }{}
+		if err := d.Decode(&row); err != nil {
+			return nil, err
+		}
+		objects = append(objects, row.Object)
+	}
+	return objects, nil
+}
+
+func TestComputedColumns(t *testing.T) {
+	pod := func(name string, priority float64, restarts ...interface{}) map[string]interface{} {
+		object := fakePod("default", name, "Running", nil)
+		containers, statuses := []interface{}{}, []interface{}{}
+		for _, r := range restarts {
+			containers = append(containers, map[string]interface{}{"name": "c"})
+			statuses = append(statuses, map[string]interface{}{"restartCount": r})
+		}
+		object["spec"] = map[string]interface{}{"priority": priority, "containers": containers}
+		object["status"].(map[string]interface{})["containerStatuses"] = statuses
+		return object
+	}
+	s := newFakeServer(t, map[string][]map[string]interface{}{
+		"pods": {pod("web-0", 10, 1, 2), pod("web-1", 5, 0)},
+	})
+	config := &rest.Config{Host: s.URL}
+
+	tests := []struct {
+		name   string
+		query  string
+		titles []string
+		want   [][]interface{}
+	}{
+		{
+			name:   "aliases",
+			query:  "SELECT name, spec.priority - 1 AS lower, len(spec.containers) AS containers, sum(status.containerStatuses[*].restartCount) AS restarts FROM */pods",
+			titles: []string{"name", "lower", "containers", "restarts"},
+			want:   [][]interface{}{{"web-0", float64(9), float64(2), float64(3)}, {"web-1", float64(4), float64(1), float64(0)}},
+		},
+		{
+			name:   "default titles",
+			query:  "SELECT spec.priority*2, len( spec.containers ) FROM */pods",
+			titles: []string{"spec.priority*2", "len( spec.containers )"},
+			want:   [][]interface{}{{float64(20), float64(2)}, {float64(10), float64(1)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := queryObjects(t, config, tt.query)
+			if err != nil {
+				t.Fatalf("query error = %v", err)
+			}
+
+			got := [][]interface{}{}
+			for _, row := range rows {
+				values := []interface{}{}
+				for _, title := range tt.titles {
+					values = append(values, row[title])
+				}
+				got = append(got, values)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("query rows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnion(t *testing.T) {
+	s := newFakeServer(t, map[string][]map[string]interface{}{
+		"pods": {
+			fakePod("default", "web-0", "Running", nil),
+			fakePod("test", "web-0", "Running", nil),
+			fakePod("default", "db-0", "Pending", nil),
+		},
+	})
+	config := &rest.Config{Host: s.URL}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "union removes duplicate rows",
+			query: "SELECT name FROM */pods WHERE phase = 'Running' UNION SELECT name FROM */pods ORDER BY name",
+			want:  []string{"db-0", "web-0"},
+		},
+		{
+			name:  "union all keeps duplicate rows",
+			query: "SELECT name FROM */pods WHERE phase = 'Running' UNION ALL SELECT name FROM */pods ORDER BY name",
+			want:  []string{"db-0", "web-0", "web-0", "web-0", "web-0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := queryRowNames(t, config, tt.query)
+			if err != nil {
+				t.Fatalf("query error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("query rows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamedQueryListedOnce(t *testing.T) {
+	s := newFakeServer(t, map[string][]map[string]interface{}{
+		"pods": {
+			fakePod("default", "web-0", "Running", nil),
+			fakePod("default", "web-1", "Pending", nil),
+		},
+	})
+	config := &rest.Config{Host: s.URL}
+
+	q := "WITH running AS (SELECT name, phase FROM */pods WHERE phase = 'Running') " +
+		"SELECT name FROM running UNION ALL SELECT name FROM running"
+	got, err := queryRowNames(t, config, q)
+	if err != nil {
+		t.Fatalf("query error = %v", err)
+	}
+	if want := []string{"web-0", "web-0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("query rows = %v, want %v", got, want)
+	}
+	if len(s.requests) != 1 {
+		t.Errorf("list requests = %v, want one request", s.requests)
+	}
+}
+
+func TestServerSelectors(t *testing.T) {
+	s := newFakeServer(t, map[string][]map[string]interface{}{
+		"pods": {
+			fakePod("default", "web-0", "Running", map[string]interface{}{"app": "web"}),
+			fakePod("default", "db-0", "Running", map[string]interface{}{"app": "db"}),
+		},
+	})
+	config := &rest.Config{Host: s.URL}
+
+	// The fake server ignores selectors, listed items are still filtered by the query.
+	got, err := queryRowNames(t, config, "SELECT name FROM */pods WHERE labels.app = 'web' AND phase = 'Running'")
+	if err != nil {
+		t.Fatalf("query error = %v", err)
+	}
+	if want := []string{"web-0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("query rows = %v, want %v", got, want)
+	}
+	if len(s.requests) != 1 || !strings.Contains(s.requests[0], "labelSelector=app%3Dweb") ||
+		!strings.Contains(s.requests[0], "fieldSelector=status.phase%3DRunning") {
+		t.Errorf("list requests = %v, want a request with label selector app=web and field selector status.phase=Running", s.requests)
+	}
+}
@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yaacov/tree-search-language/v6/pkg/tsl"
+	"github.com/yaacov/tree-search-language/v6/pkg/walkers/ident"
+	"github.com/yaacov/tree-search-language/v6/pkg/walkers/semantics"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+
+	"github.com/yaacov/kubectl-sql/pkg/eval"
+	"github.com/yaacov/kubectl-sql/pkg/printers"
+	"github.com/yaacov/kubectl-sql/pkg/query"
+)
+
+// requestedSubquery is a subquery of the WHERE clause.
+type requestedSubquery struct {
+	options *SQLOptions
+	// exists is true for EXISTS subqueries, that evaluate to true if the subquery has
+	// rows, other subqueries evaluate to the values of their selected field.
+	exists bool
+	// correlated is true for subqueries referring to fields of the outer query,
+	// correlated subqueries are run for each item of the outer query.
+	correlated bool
+
+	// items are the listed items of the subquery resources.
+	items []unstructured.Unstructured
+	// literal is the result of an uncorrelated subquery as a TSL literal, set when the subquery is run.
+	literal string
+}
+
+// whereExpr renders the WHERE clause as TSL, subqueries are replaced by placeholder identifiers.
+func (o *SQLOptions) whereExpr(e *query.Expr) (string, error) {
+	return e.TSL(func(c *query.Call) (string, error) {
+		if c.Subquery == nil {
+			return o.callExpr(c)
+		}
+		return o.parseSubquery(c)
+	})
+}
+
+// parseSubquery validates a subquery, and returns its placeholder identifier.
+func (o *SQLOptions) parseSubquery(c *query.Call) (string, error) {
+	stmt := c.Subquery.Statement
+	s := &requestedSubquery{
+		options: o.queryOptions(),
+		exists:  strings.EqualFold(c.Name, "EXISTS"),
+	}
+	s.options.outer = o
+
+	if len(stmt.Unions) > 0 {
+		return "", fmt.Errorf("UNION is not supported in subqueries: %s", c.Subquery.Text)
+	}
+	if !s.exists && len(stmt.Fields) != 1 {
+		return "", fmt.Errorf("subquery must select exactly one field: %s", c.Subquery.Text)
+	}
+	if err := s.options.completeStatement(stmt); err != nil {
+		return "", err
+	}
+
+	correlated, err := s.options.isCorrelated()
+	if err != nil {
+		return "", err
+	}
+	s.correlated = correlated
+
+	if o.requestedSubqueries == nil {
+		o.requestedSubqueries = map[string]*requestedSubquery{}
+	}
+	name := fmt.Sprintf("_subquery%d", len(o.requestedSubqueries))
+	o.requestedSubqueries[name] = s
+	return name, nil
+}
+
+// queryOptions returns the options of a query nested in this query, e.g. a subquery,
+// nested queries use the aliases, the default namespace and the named queries of this query.
+func (o *SQLOptions) queryOptions() *SQLOptions {
+	s := &SQLOptions{
+		configFlags:     o.configFlags,
+		rawConfig:       o.rawConfig,
+		namespace:       o.namespace,
+		outputFormat:    o.outputFormat,
+		chunkSize:       o.chunkSize,
+		discoveryClient: o.discoveryClient,
+		IOStreams:       o.IOStreams,
+		requestedCTEs:   o.requestedCTEs,
+	}
+	initializeDefaults(s)
+
+	// Aliases and fields selected by the nested query do not apply to this query.
+	s.defaultAliases = map[string]string{}
+	for k, v := range o.defaultAliases {
+		s.defaultAliases[k] = v
+	}
+	s.defaultTableFields = printers.TableFieldsMap{}
+	for k, v := range o.defaultTableFields {
+		s.defaultTableFields[k] = v
+	}
+
+	return s
+}
+
+// isCorrelated checks if the WHERE clause of a subquery refers to fields of the outer query.
+func (o *SQLOptions) isCorrelated() (bool, error) {
+	queries := []string{o.requestedQuery}
+	for _, call := range o.requestedCalls {
+		queries = append(queries, call.Args...)
+	}
+
+	correlated := false
+	for _, q := range queries {
+		if q == "" {
+			continue
+		}
+
+		tree, err := tsl.ParseTSL(q)
+		if err != nil {
+			return false, err
+		}
+		_, err = ident.Walk(tree, func(s string) (string, error) {
+			correlated = correlated || o.isOuterField(s)
+			return s, nil
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return correlated, nil
+}
+
+// bindSubqueries runs the subqueries of the WHERE clause, uncorrelated subqueries are run once
+// and bound as literals, correlated subqueries are run for each item of the outer query.
+func (o *SQLOptions) bindSubqueries(ctx context.Context, config *rest.Config) error {
+	correlated := false
+	for _, s := range o.requestedSubqueries {
+		if err := s.options.bindSubqueries(ctx, config); err != nil {
+			return err
+		}
+
+		items, err := s.options.listItems(ctx, config, 0, nil)
+		if err != nil {
+			return err
+		}
+		s.items = items
+
+		if s.correlated {
+			correlated = true
+			continue
+		}
+
+		value, err := s.evaluate(nil)
+		if err != nil {
+			return err
+		}
+		if s.literal, err = tslLiteral(value); err != nil {
+			return err
+		}
+	}
+
+	if correlated {
+		factory := o.evalFunctionFactory
+		if factory == nil {
+			factory = eval.EvalFunctionFactory
+		}
+		o.evalFunctionFactory = o.subqueryEvalFunctionFactory(factory)
+	}
+
+	return nil
+}
+
+// subqueryEvalFunctionFactory wraps an evaluation method factory, so that the placeholder
+// identifiers of correlated subqueries evaluate to the subquery results for each item.
+func (o *SQLOptions) subqueryEvalFunctionFactory(factory func(item unstructured.Unstructured) semantics.EvalFunc) func(item unstructured.Unstructured) semantics.EvalFunc {
+	return func(item unstructured.Unstructured) semantics.EvalFunc {
+		evalItem := factory(item)
+
+		// Subqueries qualify fields of the outer query by table name, the
+		// items of queries without joins are evaluated without it.
+		evalOuter := func(key string) (interface{}, bool) {
+			if o.requestedJoin != nil {
+				return evalItem(key)
+			}
+			return evalItem(key[strings.Index(key, ".")+1:])
+		}
+
+		return func(key string) (interface{}, bool) {
+			s, ok := o.requestedSubqueries[key]
+			if !ok || !s.correlated {
+				return evalItem(key)
+			}
+
+			// Subqueries that fail to run return missing values.
+			value, err := s.evaluate(evalOuter)
+			if err != nil {
+				return nil, true
+			}
+			return value, true
+		}
+	}
+}
+
+// evaluate runs the subquery on its listed items, outer evaluates the fields of
+// the outer query in correlated subqueries.
+func (s *requestedSubquery) evaluate(outer semantics.EvalFunc) (interface{}, error) {
+	o := s.options
+	items := s.items
+
+	if len(o.requestedQuery) > 0 {
+		f := o.filterConfig(o.requestedQuery)
+		if outer != nil {
+			f.EvalFunctionFactory = o.correlatedEvalFunctionFactory(outer)
+		}
+
+		var err error
+		if items, err = f.Filter(items); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := o.resultRows(items)
+	if err != nil {
+		return nil, err
+	}
+	p := o.printerConfig()
+	rows = p.Window(rows)
+
+	if s.exists {
+		return len(rows) > 0, nil
+	}
+	return s.values(rows), nil
+}
+
+// correlatedEvalFunctionFactory returns the evaluation method factory of the items of a
+// correlated subquery, fields qualified by table names of the outer query are evaluated by outer.
+func (o *SQLOptions) correlatedEvalFunctionFactory(outer semantics.EvalFunc) func(item unstructured.Unstructured) semantics.EvalFunc {
+	factory := o.evalFunctionFactory
+	if factory == nil {
+		factory = eval.EvalFunctionFactory
+	}
+
+	return func(item unstructured.Unstructured) semantics.EvalFunc {
+		evalItem := factory(item)
+		return func(key string) (interface{}, bool) {
+			if o.isOuterField(key) {
+				return outer(key)
+			}
+			return evalItem(key)
+		}
+	}
+}
+
+// values returns the values of the selected field of the subquery rows,
+// array values are flattened and missing values are skipped.
+func (s *requestedSubquery) values(rows []unstructured.Unstructured) []interface{} {
+	var title string
+	if g := s.options.requestedGroupBy; g != nil {
+		title = g.columns[0].Title
+	} else {
+		title = s.options.requestedColumns.columns[0].Title
+	}
+
+	values := []interface{}{}
+	for _, row := range rows {
+		switch v := row.Object[title].(type) {
+		case nil:
+		case []interface{}:
+			for _, e := range v {
+				if e != nil {
+					values = append(values, e)
+				}
+			}
+		default:
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// tslLiteral renders the result of a subquery as a TSL literal, e.g. true or ['a', 'b'].
+func tslLiteral(value interface{}) (string, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return fmt.Sprintf("%v", value), nil
+	}
+
+	literals := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case string:
+			literals[i] = query.QuoteString(v)
+		case float64:
+			literals[i] = strconv.FormatFloat(v, 'g', -1, 64)
+		case int64:
+			literals[i] = strconv.FormatInt(v, 10)
+		case bool:
+			literals[i] = strconv.FormatBool(v)
+		case time.Time:
+			literals[i] = query.QuoteString(v.Format(time.RFC3339))
+		default:
+			return "", fmt.Errorf("unsupported subquery value: %v", v)
+		}
+	}
+	return "[" + strings.Join(literals, ", ") + "]", nil
+}
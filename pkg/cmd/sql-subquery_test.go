@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestSubqueryAliases(t *testing.T) {
+	claim := func(namespace, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "PersistentVolumeClaim",
+			"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+		}
+	}
+	mounting := func(pod map[string]interface{}, claimName string) map[string]interface{} {
+		pod["spec"] = map[string]interface{}{
+			"volumes": []interface{}{
+				map[string]interface{}{"name": "data", "persistentVolumeClaim": map[string]interface{}{"claimName": claimName}},
+			},
+		}
+		return pod
+	}
+
+	s := newFakeServer(t, map[string][]map[string]interface{}{
+		"pods": {
+			mounting(fakePod("default", "pod-10", "Running", nil), "data-10"),
+			fakePod("default", "pod-11", "Running", nil),
+		},
+		"persistentvolumeclaims": {
+			claim("default", "data-10"),
+			claim("default", "data-11"),
+		},
+	})
+	config := &rest.Config{Host: s.URL}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "correlated",
+			query: "SELECT name FROM */persistentvolumeclaims c WHERE EXISTS (SELECT name FROM */pods p WHERE p.spec.volumes[0].persistentVolumeClaim.claimName = c.name)",
+			want:  []string{"data-10"},
+		},
+		{
+			name:  "correlated not exists",
+			query: "SELECT name FROM */persistentvolumeclaims c WHERE NOT EXISTS (SELECT p.name FROM */pods p WHERE p.spec.volumes[0].persistentVolumeClaim.claimName = c.name)",
+			want:  []string{"data-11"},
+		},
+		{
+			name:  "uncorrelated",
+			query: "SELECT name FROM */persistentvolumeclaims WHERE EXISTS (SELECT p.name FROM */pods p WHERE p.name = 'pod-10')",
+			want:  []string{"data-10", "data-11"},
+		},
+		{
+			name:  "in",
+			query: "SELECT name FROM */persistentvolumeclaims WHERE name IN (SELECT p.spec.volumes[0].persistentVolumeClaim.claimName FROM */pods p WHERE p.name = 'pod-10')",
+			want:  []string{"data-10"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := queryRowNames(t, config, tt.query)
+			if err != nil {
+				t.Fatalf("query error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("query rows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
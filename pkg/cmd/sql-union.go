@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+
+	"github.com/yaacov/kubectl-sql/pkg/filter"
+	"github.com/yaacov/kubectl-sql/pkg/printers"
+	"github.com/yaacov/kubectl-sql/pkg/query"
+)
+
+// requestedUnion is a list of queries whose rows are combined using UNION [ALL].
+type requestedUnion struct {
+	queries []*SQLOptions
+	// all is true for queries combined using UNION ALL, the value of the first query is not used.
+	all []bool
+	// titles are the column titles of the combined rows, named by the first query.
+	titles []string
+}
+
+// parseUnion validates the queries of a UNION, the ORDER BY, LIMIT and OFFSET
+// clauses apply to the combined rows.
+func (o *SQLOptions) parseUnion(stmt *query.Statement) error {
+	first := *stmt
+	first.With, first.Unions, first.OrderBy, first.Limit, first.Offset = nil, nil, nil, 0, 0
+
+	statements := []*query.Statement{&first}
+	u := &requestedUnion{all: []bool{false}}
+	for _, union := range stmt.Unions {
+		statements = append(statements, union.Statement)
+		u.all = append(u.all, union.All)
+	}
+
+	for i, s := range statements {
+		if s.Fields == nil {
+			return fmt.Errorf("SELECT * is not allowed with UNION, select the combined fields")
+		}
+
+		q := o.queryOptions()
+		if err := q.completeStatement(s); err != nil {
+			return err
+		}
+
+		titles := q.selectedTitles()
+		if i == 0 {
+			u.titles = titles
+			o.defaultTableFields[printers.SelectedFields] = q.defaultTableFields[printers.SelectedFields]
+		} else if len(titles) != len(u.titles) {
+			return fmt.Errorf("queries combined with UNION must select the same number of fields: got %d and %d", len(u.titles), len(titles))
+		}
+
+		u.queries = append(u.queries, q)
+		o.requestedResources = append(o.requestedResources, q.requestedResources...)
+	}
+
+	o.requestedUnion = u
+	o.limit = stmt.Limit
+	o.offset = stmt.Offset
+	return o.parseUnionOrderBy(stmt.OrderBy)
+}
+
+// parseUnionOrderBy extracts and validates the ORDER BY clause of a UNION,
+// sort keys must be columns of the combined rows.
+func (o *SQLOptions) parseUnionOrderBy(items []query.OrderItem) error {
+	orderByFields := make([]printers.OrderByField, 0, len(items))
+
+	for _, item := range items {
+		if item.Position > 0 {
+			if item.Position > len(o.requestedUnion.titles) {
+				return fmt.Errorf("ORDER BY position %d is not in the list of selected fields", item.Position)
+			}
+			orderByFields = append(orderByFields, orderByField(item, o.requestedUnion.titles[item.Position-1]))
+			continue
+		}
+
+		title, ok := "", false
+		for _, t := range o.requestedUnion.titles {
+			if strings.EqualFold(t, item.Expr.String()) {
+				title, ok = t, true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("ORDER BY field of UNION must be a selected field of the first query: %s", item.Expr)
+		}
+
+		orderByFields = append(orderByFields, orderByField(item, title))
+	}
+
+	o.orderByFields = orderByFields
+	return nil
+}
+
+// unionRows returns the combined rows of the queries of a UNION.
+func (o *SQLOptions) unionRows(ctx context.Context, config *rest.Config) ([]unstructured.Unstructured, error) {
+	u := o.requestedUnion
+
+	rows := []unstructured.Unstructured{}
+	for i, q := range u.queries {
+		queryRows, err := q.queryRows(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, u.renameColumns(q.selectedTitles(), queryRows)...)
+
+		// UNION removes duplicates from all the rows combined so far.
+		if i > 0 && !u.all[i] {
+			rows = filter.Distinct(rows, u.titles)
+		}
+	}
+
+	return rows, nil
+}
+
+// renameColumns returns the rows of a query keyed by the column titles of the combined rows.
+func (u *requestedUnion) renameColumns(titles []string, rows []unstructured.Unstructured) []unstructured.Unstructured {
+	renamed := make([]unstructured.Unstructured, len(rows))
+	for i, row := range rows {
+		object := map[string]interface{}{"kind": row.Object["kind"]}
+		for j, title := range titles {
+			object[u.titles[j]] = row.Object[title]
+		}
+		renamed[i] = unstructured.Unstructured{Object: object}
+	}
+	return renamed
+}
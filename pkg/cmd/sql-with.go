@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+
+	"github.com/yaacov/kubectl-sql/pkg/eval"
+	"github.com/yaacov/kubectl-sql/pkg/printers"
+	"github.com/yaacov/kubectl-sql/pkg/query"
+)
+
+// requestedCTE is a named query of the WITH clause, used as a resource by the queries following it.
+type requestedCTE struct {
+	options *SQLOptions
+	// rows is true for queries selecting fields, that result in rows keyed by column title,
+	// other queries result in the listed items.
+	rows bool
+
+	// items are the result items of the query, listed once when the query is first used.
+	items  []unstructured.Unstructured
+	listed bool
+}
+
+// parseWith validates the named queries of the WITH clause, each query may use the queries before it.
+func (o *SQLOptions) parseWith(ctes []query.CTE) error {
+	if len(ctes) == 0 {
+		return nil
+	}
+
+	if o.requestedCTEs == nil {
+		o.requestedCTEs = map[string]*requestedCTE{}
+	}
+	for _, cte := range ctes {
+		c := &requestedCTE{options: o.queryOptions()}
+		if err := c.options.completeStatement(cte.Statement); err != nil {
+			return err
+		}
+		c.rows = c.options.requestedColumns != nil || c.options.requestedGroupBy != nil || c.options.requestedUnion != nil
+
+		o.requestedCTEs[strings.ToLower(cte.Name)] = c
+	}
+
+	return nil
+}
+
+// lookupCTE returns the named query of the WITH clause used as resource.
+func (o *SQLOptions) lookupCTE(name string) (*requestedCTE, bool) {
+	c, ok := o.requestedCTEs[strings.ToLower(name)]
+	return c, ok
+}
+
+// parseCTEResources sets the evaluation of queries using the rows of a named query selecting fields,
+// the fields of these rows are their column titles.
+func (o *SQLOptions) parseCTEResources() error {
+	resources := o.requestedResources
+	if o.requestedJoin != nil {
+		resources = append(resources, o.requestedJoin.resource)
+	}
+
+	for _, r := range resources {
+		if r.cte == nil || !r.cte.rows {
+			continue
+		}
+		if len(resources) > 1 {
+			return fmt.Errorf("WITH query %s selects fields, and must be the only resource of the query", r.name)
+		}
+
+		fields := r.cte.options.defaultTableFields[printers.SelectedFields]
+		o.rowTitles = map[string]bool{}
+		for _, field := range fields {
+			o.rowTitles[field.Name] = true
+		}
+
+		// Queries selecting "*" print the columns of the named query.
+		o.defaultTableFields[printers.SelectedFields] = fields
+		o.evalFunctionFactory = eval.RowEvalFunctionFactory
+	}
+
+	return nil
+}
+
+// list returns the result items of a named query, the query is run only once, and
+// its items are reused by every query using it.
+func (c *requestedCTE) list(ctx context.Context, config *rest.Config) ([]unstructured.Unstructured, error) {
+	if c.listed {
+		return c.items, nil
+	}
+
+	o := c.options
+	rows, err := o.queryRows(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// ORDER BY, LIMIT and OFFSET clauses of the named query apply to its result.
+	p := o.printerConfig()
+	rows = p.Window(rows)
+	if o.requestedColumns != nil {
+		rows = o.requestedColumns.visibleRows(rows)
+	}
+
+	c.items, c.listed = rows, true
+	return c.items, nil
+}
@@ -21,18 +21,25 @@ package cmd
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/yaacov/kubectl-sql/pkg/eval"
 )
 
+// paramPattern matches the names of query parameters, e.g. ns for :ns.
+var paramPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
 // NewSQLOptions provides an instance of SQLOptions with default values
 func NewSQLOptions(streams genericclioptions.IOStreams) *SQLOptions {
 	options := &SQLOptions{
 		configFlags:  genericclioptions.NewConfigFlags(true),
 		IOStreams:    streams,
 		outputFormat: "table",
+		chunkSize:    500,
 	}
 
 	// Initialize default configuration
@@ -52,9 +59,16 @@ func NewCmdSQL(streams genericclioptions.IOStreams) *cobra.Command {
 		Example:          sqlCmdExample,
 		TraverseChildren: true,
 		RunE: func(c *cobra.Command, args []string) error {
-			if len(args) == 0 {
+			if len(args) == 0 && o.filename == "" {
 				return fmt.Errorf(errUsageTemplate, "missing SQL query")
 			}
+			if len(args) > 0 && o.filename != "" {
+				return fmt.Errorf(errUsageTemplate, "use either a SQL query or a query script file")
+			}
+
+			if o.filename != "" {
+				return o.runScriptFile(c, args)
+			}
 
 			// All arguments should be treated as a single SQL query
 			query := strings.Join(args, " ")
@@ -117,6 +131,16 @@ func NewCmdSQL(streams genericclioptions.IOStreams) *cobra.Command {
 		"Output format. One of: json|yaml|table|name")
 	cmd.Flags().BoolVarP(&o.noHeaders, "no-headers", "H", false,
 		"When using the table output format, don't print headers (column titles)")
+	cmd.Flags().StringArrayVar(&o.paramArgs, "param", nil,
+		"Bind a query parameter, e.g. --param ns=prod for :ns, may be repeated")
+	cmd.Flags().StringVarP(&o.filename, "filename", "f", "",
+		"Run the statements of a query script file, separated by semicolons, use - to read the standard input")
+	cmd.Flags().BoolVar(&o.continueOnError, "continue-on-error", false,
+		"When running a query script, run the following statements after a statement fails, the command still exits with an error if any statement failed")
+	cmd.Flags().Int64Var(&o.chunkSize, "chunk-size", o.chunkSize,
+		"Return large lists in chunks rather than all at once, pass 0 to disable")
+	cmd.Flags().BoolVar(&o.refreshDiscovery, "refresh-discovery", false,
+		"Look up the resources of the server again, instead of using the cached resources")
 
 	o.configFlags.AddFlags(cmd.Flags())
 
@@ -135,9 +159,58 @@ func (o *SQLOptions) Complete(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if o.params, err = parseParams(o.paramArgs); err != nil {
+		return err
+	}
+
+	// Resources of the server are cached on disk, see the --cache-dir flag.
+	if o.discoveryClient, err = o.configFlags.ToDiscoveryClient(); err != nil {
+		return err
+	}
+	if o.refreshDiscovery {
+		o.discoveryClient.Invalidate()
+	}
+
 	return nil
 }
 
+// runScriptFile runs the statements of the query script file.
+func (o *SQLOptions) runScriptFile(c *cobra.Command, args []string) error {
+	if err := o.Complete(c, args); err != nil {
+		return err
+	}
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	config, err := o.rawConfig.ClientConfig()
+	if err != nil {
+		return err
+	}
+
+	script, err := o.readScript()
+	if err != nil {
+		return err
+	}
+
+	return o.RunScript(config, script)
+}
+
+// parseParams parses the values of query parameters given as name=value, values are
+// typed the same way values of labels are, e.g. "3" is a number and "2025-01-01" a date.
+func parseParams(args []string) (map[string]interface{}, error) {
+	params := map[string]interface{}{}
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || !paramPattern.MatchString(name) {
+			return nil, fmt.Errorf(errUsageTemplate, fmt.Sprintf("invalid parameter %q, expected name=value", arg))
+		}
+		params[name] = eval.InferValue(value)
+	}
+	return params, nil
+}
+
 // Validate ensures that all required arguments and flag values are provided
 func (o *SQLOptions) Validate() error {
 	formatOptions := map[string]bool{"table": true, "json": true, "yaml": true, "name": true}
@@ -145,6 +218,9 @@ func (o *SQLOptions) Validate() error {
 	if _, ok := formatOptions[o.outputFormat]; !ok {
 		return fmt.Errorf("output format must be one of: json|yaml|table|name")
 	}
+	if o.chunkSize < 0 {
+		return fmt.Errorf("chunk size must not be negative")
+	}
 
 	return nil
 }
@@ -0,0 +1,185 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Aggregator accumulates the values of an aggregate function.
+type Aggregator interface {
+	// Add adds a value to the aggregate, nil values are ignored
+	// and arrays add each of their elements.
+	Add(value interface{}) error
+	// Result returns the aggregated value.
+	Result() interface{}
+}
+
+// IsAggregate checks if a function name is an aggregate function.
+func IsAggregate(name string) bool {
+	switch strings.ToLower(name) {
+	case "count", "sum", "avg", "min", "max":
+		return true
+	}
+	return false
+}
+
+// NewAggregator returns an aggregator for an aggregate function name, e.g. "count" or "sum".
+func NewAggregator(name string) (Aggregator, error) {
+	switch strings.ToLower(name) {
+	case "count":
+		return &countAggregator{}, nil
+	case "sum":
+		return &sumAggregator{name: "SUM"}, nil
+	case "avg":
+		return &sumAggregator{name: "AVG", average: true}, nil
+	case "min":
+		return &extremeAggregator{name: "MIN", less: true}, nil
+	case "max":
+		return &extremeAggregator{name: "MAX"}, nil
+	}
+	return nil, fmt.Errorf("unknown aggregate function: %s", name)
+}
+
+// addValues calls add for a value, or for each element of an array value, skipping nil values.
+func addValues(value interface{}, add func(v interface{}) error) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		for _, element := range v {
+			if err := addValues(element, add); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return add(value)
+}
+
+// numericValue converts a number value to float64.
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+type countAggregator struct {
+	count float64
+}
+
+func (a *countAggregator) Add(value interface{}) error {
+	return addValues(value, func(v interface{}) error {
+		a.count++
+		return nil
+	})
+}
+
+func (a *countAggregator) Result() interface{} {
+	return a.count
+}
+
+// sumAggregator implements SUM and AVG, numbers with SI and IEC suffixes, e.g. 20Gi,
+// are already parsed to numbers when values are extracted.
+type sumAggregator struct {
+	name    string
+	average bool
+	sum     float64
+	count   float64
+}
+
+func (a *sumAggregator) Add(value interface{}) error {
+	return addValues(value, func(v interface{}) error {
+		n, ok := numericValue(v)
+		if !ok {
+			return fmt.Errorf("%s: value is not a number: %v", a.name, v)
+		}
+
+		a.sum += n
+		a.count++
+		return nil
+	})
+}
+
+func (a *sumAggregator) Result() interface{} {
+	if !a.average {
+		return a.sum
+	}
+	if a.count == 0 {
+		return nil
+	}
+	return a.sum / a.count
+}
+
+// extremeAggregator implements MIN and MAX of numbers, strings and dates.
+type extremeAggregator struct {
+	name  string
+	less  bool
+	value interface{}
+}
+
+func (a *extremeAggregator) Add(value interface{}) error {
+	return addValues(value, func(v interface{}) error {
+		if n, ok := numericValue(v); ok {
+			v = n
+		}
+		if a.value == nil {
+			a.value = v
+			return nil
+		}
+
+		cmp, err := compareValues(v, a.value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", a.name, err)
+		}
+		if (a.less && cmp < 0) || (!a.less && cmp > 0) {
+			a.value = v
+		}
+		return nil
+	})
+}
+
+func (a *extremeAggregator) Result() interface{} {
+	return a.value
+}
+
+// compareValues compares two values of the same type.
+func compareValues(a, b interface{}) (int, error) {
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			switch {
+			case x < y:
+				return -1, nil
+			case x > y:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), nil
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, nil
+			case !x:
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, fmt.Errorf("can not compare values %v and %v", a, b)
+}
@@ -0,0 +1,69 @@
+package eval
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAggregator(t *testing.T) {
+	early := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		function string
+		values   []interface{}
+		expected interface{}
+		wantErr  bool
+	}{
+		{name: "count skips nil", function: "COUNT", values: []interface{}{"a", nil, "b"}, expected: float64(2)},
+		{name: "count array elements", function: "count", values: []interface{}{[]interface{}{"a", "b"}, "c"}, expected: float64(3)},
+		{name: "count empty", function: "count", values: []interface{}{}, expected: float64(0)},
+		{name: "sum numbers", function: "sum", values: []interface{}{float64(1), int64(2), float64(3.5)}, expected: float64(6.5)},
+		{name: "sum quantities", function: "sum", values: []interface{}{stringValue("1Gi"), stringValue("512Mi")}, expected: float64(1536 * 1024 * 1024)},
+		{name: "sum empty", function: "sum", values: []interface{}{}, expected: float64(0)},
+		{name: "sum strings", function: "sum", values: []interface{}{"abc"}, wantErr: true},
+		{name: "avg numbers", function: "avg", values: []interface{}{float64(1), float64(2), nil, float64(6)}, expected: float64(3)},
+		{name: "avg empty", function: "avg", values: []interface{}{nil}, expected: nil},
+		{name: "min numbers", function: "min", values: []interface{}{float64(3), float64(1), float64(2)}, expected: float64(1)},
+		{name: "max numbers", function: "max", values: []interface{}{float64(3), int64(7), float64(2)}, expected: float64(7)},
+		{name: "min strings", function: "min", values: []interface{}{"b", "a", "c"}, expected: "a"},
+		{name: "max dates", function: "max", values: []interface{}{early, late}, expected: late},
+		{name: "max mixed", function: "max", values: []interface{}{"a", float64(1)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aggregator, err := NewAggregator(tt.function)
+			if err != nil {
+				t.Fatalf("NewAggregator() error = %v", err)
+			}
+
+			for _, v := range tt.values {
+				if err = aggregator.Add(v); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Add() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := aggregator.Result(); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Result() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewAggregatorUnknown(t *testing.T) {
+	if IsAggregate("lower") {
+		t.Errorf("IsAggregate(lower) = true, want false")
+	}
+	if _, err := NewAggregator("lower"); err == nil {
+		t.Errorf("NewAggregator(lower) expected error")
+	}
+}
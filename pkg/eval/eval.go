@@ -23,6 +23,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -56,16 +57,34 @@ func ExtractValue(item unstructured.Unstructured, key string) (interface{}, bool
 		return handleMetadataValue(value, ok)
 	}
 
+	// Check for quoted label and annotation keys, e.g. labels['app.kubernetes.io/name'].
+	if name, ok := quotedKey(key, "labels"); ok {
+		value, ok := item.GetLabels()[name]
+		return handleMetadataValue(value, ok)
+	}
+
+	if name, ok := quotedKey(key, "annotations"); ok {
+		value, ok := item.GetAnnotations()[name]
+		return handleMetadataValue(value, ok)
+	}
+
+	// Check if the path contains a wildcard pattern, ignoring quoted keys
+	path := quotedKeyPattern.ReplaceAllString(key, "['']")
+	hasWildcard := strings.Contains(path, "[*]") || strings.Contains(path, "..") ||
+		strings.Contains(path, "*") || strings.Contains(path, "?")
+
+	// JSONPath splits keys on dots, quoted keys are rendered with escaped dots,
+	// e.g. metadata.labels['app.kubernetes.io/name'] as metadata.labels.app\.kubernetes\.io/name
+	key = quotedKeyPattern.ReplaceAllStringFunc(key, func(k string) string {
+		return "." + strings.ReplaceAll(k[2:len(k)-2], ".", `\.`)
+	})
+
 	// Use Kubernetes JSONPath implementation
 	// Format the key as a proper JSONPath expression if it's not already
 	if !strings.HasPrefix(key, "{") {
 		key = fmt.Sprintf("{.%s}", key)
 	}
 
-	// Check if the path contains a wildcard pattern
-	hasWildcard := strings.Contains(key, "[*]") || strings.Contains(key, "..") ||
-		strings.Contains(key, "*") || strings.Contains(key, "?")
-
 	j := jsonpath.New("extract-value")
 	if err := j.Parse(key); err != nil {
 		return nil, true
@@ -136,6 +155,23 @@ func ExtractValue(item unstructured.Unstructured, key string) (interface{}, bool
 	return convertObjectToValue(result)
 }
 
+// quotedKeyPattern matches the quoted keys of a path, e.g. ['app.kubernetes.io/name'].
+var quotedKeyPattern = regexp.MustCompile(`\['[^'\]]*'\]`)
+
+// quotedKey returns the name of a quoted key of a map, e.g. app.kubernetes.io/name
+// for labels['app.kubernetes.io/name'].
+func quotedKey(key string, field string) (string, bool) {
+	if !strings.HasPrefix(key, field+"['") || !strings.HasSuffix(key, "']") {
+		return "", false
+	}
+
+	name := key[len(field)+2 : len(key)-2]
+	if strings.ContainsAny(name, "'[]") {
+		return "", false
+	}
+	return name, true
+}
+
 func handleMetadataValue(value string, exists bool) (interface{}, bool) {
 	if !exists {
 		return nil, true
@@ -160,6 +196,12 @@ func convertObjectToValue(object interface{}) (interface{}, bool) {
 	return nil, true
 }
 
+// InferValue converts a string to its most appropriate type, the same way
+// values of labels and annotations are converted, e.g. "3" to float64(3).
+func InferValue(s string) interface{} {
+	return inferValue(s)
+}
+
 // inferValue attempts to convert a string to its most appropriate type:
 // bool, int, float, date, or keeps it as string if no conversion works
 func inferValue(s string) interface{} {
@@ -20,10 +20,12 @@ func TestExtractValue(t *testing.T) {
 				"creationTimestamp": creationTime.Format(time.RFC3339),
 				"deletionTimestamp": deletionTime.Format(time.RFC3339),
 				"labels": map[string]interface{}{
-					"app": "test",
+					"app":                    "test",
+					"app.kubernetes.io/name": "web-frontend",
 				},
 				"annotations": map[string]interface{}{
-					"note": "test annotation",
+					"note":               "test annotation",
+					"example.com/owner*": "team-a",
 				},
 			},
 			"spec": map[string]interface{}{
@@ -130,6 +132,11 @@ func TestExtractValue(t *testing.T) {
 		{"deleted", "deleted", deletionTime.UTC(), true},
 		{"label", "labels.app", "test", true},
 		{"annotation", "annotations.note", "test annotation", true},
+		{"quoted label", "labels['app.kubernetes.io/name']", "web-frontend", true},
+		{"quoted annotation", "annotations['example.com/owner*']", "team-a", true},
+		{"missing quoted label", "labels['app.kubernetes.io/part-of']", nil, true},
+		{"quoted path", "metadata.labels['app.kubernetes.io/name']", "web-frontend", true},
+		{"quoted path with glob", "metadata.annotations['example.com/owner*']", "team-a", true},
 		{"nested spec", "spec.nested.value", "nested-value", true},
 		{"replicas", "spec.replicas", float64(3), true},
 		{"non-existent", "invalid.path", nil, true},
@@ -11,3 +11,11 @@ func EvalFunctionFactory(item unstructured.Unstructured) semantics.EvalFunc {
 		return ExtractValue(item, key)
 	}
 }
+
+// RowEvalFunctionFactory build an evaluation method for synthetic rows, e.g. the rows
+// of an aggregated query, that returns the value of a row column using its title as key.
+func RowEvalFunctionFactory(item unstructured.Unstructured) semantics.EvalFunc {
+	return func(key string) (interface{}, bool) {
+		return item.Object[key], true
+	}
+}
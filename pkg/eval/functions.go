@@ -0,0 +1,232 @@
+package eval
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// function is a built-in scalar function.
+type function struct {
+	minArgs int
+	// maxArgs is the maximum number of arguments, -1 for no limit.
+	maxArgs int
+	// call is the function of all call sites, nil for functions compiled for each call site.
+	call func(args []interface{}) (interface{}, error)
+}
+
+// maxPatterns is the number of compiled patterns kept by a call site of regexp_replace,
+// for patterns that are not constant.
+const maxPatterns = 64
+
+var functions = map[string]function{
+	"lower": {1, 1, func(args []interface{}) (interface{}, error) {
+		return mapString(args[0], strings.ToLower), nil
+	}},
+	"upper": {1, 1, func(args []interface{}) (interface{}, error) {
+		return mapString(args[0], strings.ToUpper), nil
+	}},
+	"trim": {1, 2, func(args []interface{}) (interface{}, error) {
+		if len(args) == 2 {
+			cutset := toString(args[1])
+			return mapString(args[0], func(s string) string { return strings.Trim(s, cutset) }), nil
+		}
+		return mapString(args[0], strings.TrimSpace), nil
+	}},
+	"length": {1, 1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case nil:
+			return nil, nil
+		case []interface{}:
+			return float64(len(v)), nil
+		}
+		return float64(len([]rune(toString(args[0])))), nil
+	}},
+	"concat": {1, -1, func(args []interface{}) (interface{}, error) {
+		var b strings.Builder
+		for _, arg := range args {
+			b.WriteString(toString(arg))
+		}
+		return b.String(), nil
+	}},
+	"coalesce": {1, -1, func(args []interface{}) (interface{}, error) {
+		for _, arg := range args {
+			if arg != nil {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	}},
+	"replace": {3, 3, func(args []interface{}) (interface{}, error) {
+		from, to := toString(args[1]), toString(args[2])
+		return mapString(args[0], func(s string) string { return strings.ReplaceAll(s, from, to) }), nil
+	}},
+	"regexp_replace": {3, 3, nil},
+	"substr": {2, 3, func(args []interface{}) (interface{}, error) {
+		start, ok := numericValue(args[1])
+		if !ok {
+			return nil, fmt.Errorf("substr: start is not a number: %v", args[1])
+		}
+		length := -1.0
+		if len(args) == 3 {
+			if length, ok = numericValue(args[2]); !ok || length < 0 {
+				return nil, fmt.Errorf("substr: invalid length: %v", args[2])
+			}
+		}
+		return mapString(args[0], func(s string) string { return substr(s, int(start), int(length)) }), nil
+	}},
+	"split_part": {3, 3, func(args []interface{}) (interface{}, error) {
+		n, ok := numericValue(args[2])
+		if !ok || n == 0 {
+			return nil, fmt.Errorf("split_part: invalid field position: %v", args[2])
+		}
+		delimiter := toString(args[1])
+		return mapString(args[0], func(s string) string { return splitPart(s, delimiter, int(n)) }), nil
+	}},
+}
+
+// compilers return the function of one call site, given the values of its constant arguments,
+// e.g. to compile a constant pattern once.
+var compilers = map[string]func(constants []interface{}) (func(args []interface{}) (interface{}, error), error){
+	"regexp_replace": compileRegexpReplace,
+}
+
+// IsFunction checks if a function name is a built-in scalar function.
+func IsFunction(name string) bool {
+	_, ok := functions[strings.ToLower(name)]
+	return ok
+}
+
+// CheckFunction checks that a built-in scalar function exists and accepts a number of arguments.
+func CheckFunction(name string, args int) error {
+	f, ok := functions[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown function: %s", name)
+	}
+
+	if args < f.minArgs || (f.maxArgs >= 0 && args > f.maxArgs) {
+		expected := strconv.Itoa(f.minArgs)
+		switch {
+		case f.maxArgs < 0:
+			expected = "at least " + expected
+		case f.maxArgs > f.minArgs:
+			expected = fmt.Sprintf("%d to %d", f.minArgs, f.maxArgs)
+		}
+		return fmt.Errorf("wrong number of arguments for function %s: got %d, expected %s", name, args, expected)
+	}
+	return nil
+}
+
+// CompileFunction checks a call of a built-in scalar function, and returns the function of the
+// call site. Constants are the values of the constant arguments, e.g. string literals, and nil
+// for other arguments, invalid constants, e.g. invalid patterns, are errors.
+func CompileFunction(name string, constants []interface{}) (func(args []interface{}) (interface{}, error), error) {
+	if err := CheckFunction(name, len(constants)); err != nil {
+		return nil, err
+	}
+
+	if compile, ok := compilers[strings.ToLower(name)]; ok {
+		return compile(constants)
+	}
+	return functions[strings.ToLower(name)].call, nil
+}
+
+// CallFunction calls a built-in scalar function, e.g. lower or split_part.
+func CallFunction(name string, args []interface{}) (interface{}, error) {
+	call, err := CompileFunction(name, args)
+	if err != nil {
+		return nil, err
+	}
+	return call(args)
+}
+
+// compileRegexpReplace returns a regexp_replace function, that compiles a constant pattern once,
+// and caches other patterns by value.
+func compileRegexpReplace(constants []interface{}) (func(args []interface{}) (interface{}, error), error) {
+	patterns := map[string]*regexp.Regexp{}
+	if pattern, ok := constants[1].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("regexp_replace: invalid pattern %q: %v", pattern, err)
+		}
+		patterns[pattern] = re
+	}
+
+	return func(args []interface{}) (interface{}, error) {
+		pattern := toString(args[1])
+		re, ok := patterns[pattern]
+		if !ok {
+			var err error
+			if re, err = regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("regexp_replace: invalid pattern %q: %v", pattern, err)
+			}
+			if len(patterns) < maxPatterns {
+				patterns[pattern] = re
+			}
+		}
+
+		to := toString(args[2])
+		return mapString(args[0], func(s string) string { return re.ReplaceAllString(s, to) }), nil
+	}, nil
+}
+
+// toString formats a value as a string, missing values are empty strings.
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// mapString applies a string function to a value, missing values stay missing.
+func mapString(value interface{}, f func(string) string) interface{} {
+	if value == nil {
+		return nil
+	}
+	return f(toString(value))
+}
+
+// substr returns length characters starting at the 1-based position start,
+// a negative length returns the rest of the string.
+func substr(s string, start, length int) string {
+	runes := []rune(s)
+
+	begin := start - 1
+	end := len(runes)
+	if length >= 0 {
+		end = begin + length
+	}
+
+	if begin < 0 {
+		begin = 0
+	}
+	if end > len(runes) {
+		end = len(runes)
+	}
+	if begin >= end {
+		return ""
+	}
+	return string(runes[begin:end])
+}
+
+// splitPart splits a string on a delimiter and returns the 1-based field n,
+// negative positions count from the end.
+func splitPart(s, delimiter string, n int) string {
+	parts := strings.Split(s, delimiter)
+	if n < 0 {
+		n = len(parts) + n + 1
+	}
+	if n < 1 || n > len(parts) {
+		return ""
+	}
+	return parts[n-1]
+}
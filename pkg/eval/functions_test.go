@@ -0,0 +1,72 @@
+package eval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCallFunction(t *testing.T) {
+	tests := []struct {
+		name     string
+		function string
+		args     []interface{}
+		expected interface{}
+		wantErr  bool
+	}{
+		{name: "lower", function: "lower", args: []interface{}{"Web-App"}, expected: "web-app"},
+		{name: "upper case insensitive name", function: "UPPER", args: []interface{}{"web"}, expected: "WEB"},
+		{name: "lower missing value", function: "lower", args: []interface{}{nil}, expected: nil},
+		{name: "trim spaces", function: "trim", args: []interface{}{"  web "}, expected: "web"},
+		{name: "trim characters", function: "trim", args: []interface{}{"--web-", "-"}, expected: "web"},
+		{name: "length of string", function: "length", args: []interface{}{"héllo"}, expected: float64(5)},
+		{name: "length of array", function: "length", args: []interface{}{[]interface{}{"a", "b"}}, expected: float64(2)},
+		{name: "concat values", function: "concat", args: []interface{}{"ns", "/", float64(3), nil}, expected: "ns/3"},
+		{name: "coalesce", function: "coalesce", args: []interface{}{nil, "default", "other"}, expected: "default"},
+		{name: "coalesce all missing", function: "coalesce", args: []interface{}{nil, nil}, expected: nil},
+		{name: "replace", function: "replace", args: []interface{}{"a-b-c", "-", "."}, expected: "a.b.c"},
+		{name: "regexp replace pod hash", function: "regexp_replace", args: []interface{}{"web-7d4b9c-x2x9z", "-[a-z0-9]+-[a-z0-9]{5}$", ""}, expected: "web"},
+		{name: "regexp replace invalid pattern", function: "regexp_replace", args: []interface{}{"web", "(", ""}, wantErr: true},
+		{name: "substr with length", function: "substr", args: []interface{}{"kubernetes", float64(5), float64(3)}, expected: "rne"},
+		{name: "substr to end", function: "substr", args: []interface{}{"kubernetes", float64(5)}, expected: "rnetes"},
+		{name: "substr out of range", function: "substr", args: []interface{}{"kube", float64(10)}, expected: ""},
+		{name: "substr invalid start", function: "substr", args: []interface{}{"kube", "x"}, wantErr: true},
+		{name: "split part", function: "split_part", args: []interface{}{"web-7d4b9c-x2x9z", "-", float64(2)}, expected: "7d4b9c"},
+		{name: "split part from end", function: "split_part", args: []interface{}{"web-7d4b9c-x2x9z", "-", float64(-1)}, expected: "x2x9z"},
+		{name: "split part out of range", function: "split_part", args: []interface{}{"web", "-", float64(3)}, expected: ""},
+		{name: "too many arguments", function: "lower", args: []interface{}{"a", "b"}, wantErr: true},
+		{name: "too few arguments", function: "concat", args: []interface{}{}, wantErr: true},
+		{name: "unknown function", function: "reverse", args: []interface{}{"a"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CallFunction(tt.function, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CallFunction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("CallFunction() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompileFunction(t *testing.T) {
+	if _, err := CompileFunction("regexp_replace", []interface{}{nil, "(", nil}); err == nil {
+		t.Errorf("CompileFunction() error = nil, want invalid constant pattern error")
+	}
+
+	// Patterns that are not constant are compiled when called.
+	call, err := CompileFunction("regexp_replace", []interface{}{nil, nil, nil})
+	if err != nil {
+		t.Fatalf("CompileFunction() error = %v", err)
+	}
+	for _, pattern := range []string{"-[0-9]+$", "-[0-9]+$", "^web-"} {
+		if _, err := call([]interface{}{"web-0", pattern, ""}); err != nil {
+			t.Errorf("call(%q) error = %v", pattern, err)
+		}
+	}
+	if _, err := call([]interface{}{"web-0", "(", ""}); err == nil {
+		t.Errorf("call() error = nil, want invalid pattern error")
+	}
+}
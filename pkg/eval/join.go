@@ -0,0 +1,58 @@
+package eval
+
+import (
+	"strings"
+
+	"github.com/yaacov/tree-search-language/v6/pkg/walkers/semantics"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// JoinedItem builds an item holding the objects of joined items keyed by table alias.
+//
+// A nil item, e.g. the missing right side of a LEFT JOIN, is left out of the joined item.
+func JoinedItem(kind string, aliases []string, items []*unstructured.Unstructured) unstructured.Unstructured {
+	object := map[string]interface{}{
+		"kind": kind,
+	}
+
+	for i, alias := range aliases {
+		if items[i] != nil {
+			object[alias] = items[i].Object
+		}
+	}
+
+	return unstructured.Unstructured{Object: object}
+}
+
+// JoinedEvalFunctionFactory build an evaluation method factory for joined items.
+//
+// Keys qualified with a table alias, e.g. "p.metadata.name", are evaluated against the
+// item of that table, other keys are evaluated against the item of the first table.
+func JoinedEvalFunctionFactory(aliases []string) func(item unstructured.Unstructured) semantics.EvalFunc {
+	return func(item unstructured.Unstructured) semantics.EvalFunc {
+		return func(key string) (interface{}, bool) {
+			alias := aliases[0]
+			if i := strings.Index(key, "."); i > 0 && stringInSlice(key[:i], aliases) {
+				alias = key[:i]
+				key = key[i+1:]
+			}
+
+			object, ok := item.Object[alias].(map[string]interface{})
+			if !ok {
+				return nil, true
+			}
+
+			return ExtractValue(unstructured.Unstructured{Object: object}, key)
+		}
+	}
+}
+
+// Check if a string in slice of strings.
+func stringInSlice(a string, list []string) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
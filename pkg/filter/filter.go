@@ -33,10 +33,30 @@ import (
 type Config struct {
 	CheckColumnName func(s string) (string, error)
 	Query           string
+	// EvalFunctionFactory builds the key evaluation method for an item,
+	// if nil eval.EvalFunctionFactory is used.
+	EvalFunctionFactory func(item unstructured.Unstructured) semantics.EvalFunc
+	// Calls are the function calls used in the query, keyed by the
+	// placeholder identifiers that replace them in the query.
+	Calls map[string]Call
+	// Kind is the kind of rows aggregated from an empty list, e.g. the kind of the
+	// requested resource, rows of other lists have the kind of their items.
+	Kind string
 }
 
-// Filter filters items using query.
-func (c *Config) Filter(list []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+// Call is a call of a built-in scalar function, e.g. lower(name), or a CASE expression.
+type Call struct {
+	Function string
+	// Args are the queries of the function arguments.
+	Args []string
+}
+
+// CaseFunction is the function of CASE expressions, the arguments of CASE calls are
+// pairs of a condition and its result, optionally followed by the ELSE result.
+const CaseFunction = "case"
+
+// compile parses the query and replaces aliased identifiers.
+func (c *Config) compile() (*tsl.TSLNode, error) {
 	var (
 		tree *tsl.TSLNode
 		err  error
@@ -49,7 +69,152 @@ func (c *Config) Filter(list []unstructured.Unstructured) ([]unstructured.Unstru
 	}
 
 	// Check and replace user identifiers if alias exist.
-	newTree, err := ident.Walk(tree, c.CheckColumnName)
+	return ident.Walk(tree, c.CheckColumnName)
+}
+
+// Tree parses the query, and returns its search tree with aliased identifiers replaced.
+func (c *Config) Tree() (*tsl.TSLNode, error) {
+	return c.compile()
+}
+
+func (c *Config) evalFunctionFactory() (func(item unstructured.Unstructured) semantics.EvalFunc, error) {
+	if c.EvalFunctionFactory == nil {
+		return c.bindCalls(eval.EvalFunctionFactory)
+	}
+	return c.bindCalls(c.EvalFunctionFactory)
+}
+
+// bindCalls wraps an evaluation method factory, so that the placeholder
+// identifiers of function calls evaluate to the function results.
+func (c *Config) bindCalls(evalFunctionFactory func(item unstructured.Unstructured) semantics.EvalFunc) (func(item unstructured.Unstructured) semantics.EvalFunc, error) {
+	if len(c.Calls) == 0 {
+		return evalFunctionFactory, nil
+	}
+
+	// Compile the function arguments, and the functions of the calls.
+	args := map[string][]*tsl.TSLNode{}
+	functions := map[string]func(args []interface{}) (interface{}, error){}
+	for name, call := range c.Calls {
+		constants := []interface{}{}
+		for _, q := range call.Args {
+			a := Config{CheckColumnName: c.CheckColumnName, Query: q}
+			tree, err := a.compile()
+			if err != nil {
+				return nil, err
+			}
+			args[name] = append(args[name], tree)
+
+			var constant interface{}
+			if tree.Type() == tsl.KindStringLiteral {
+				constant = tree.Value()
+			}
+			constants = append(constants, constant)
+		}
+
+		if call.Function == CaseFunction {
+			continue
+		}
+		f, err := eval.CompileFunction(call.Function, constants)
+		if err != nil {
+			return nil, err
+		}
+		functions[name] = f
+	}
+
+	return func(item unstructured.Unstructured) semantics.EvalFunc {
+		evalItem := evalFunctionFactory(item)
+
+		var evalFunc semantics.EvalFunc
+		evalFunc = func(key string) (interface{}, bool) {
+			call, ok := c.Calls[key]
+			if !ok {
+				return evalItem(key)
+			}
+			if call.Function == CaseFunction {
+				return evalCase(args[key], evalFunc), true
+			}
+
+			values := make([]interface{}, len(args[key]))
+			for i, tree := range args[key] {
+				// Arguments that fail to evaluate are missing values.
+				values[i], _ = semantics.Walk(tree, evalFunc)
+			}
+
+			// Functions that fail to evaluate return missing values.
+			value, err := functions[key](values)
+			if err != nil {
+				return nil, true
+			}
+			return value, true
+		}
+		return evalFunc
+	}, nil
+}
+
+// evalCase evaluates the arguments of a CASE call, returning the result of the first
+// condition that is true, the ELSE result, or nil if no condition is true.
+func evalCase(args []*tsl.TSLNode, evalFunc semantics.EvalFunc) interface{} {
+	for i := 0; i+1 < len(args); i += 2 {
+		// Conditions that fail to evaluate are not true.
+		cond, err := semantics.Walk(args[i], evalFunc)
+		if match, ok := cond.(bool); err == nil && ok && match {
+			value, _ := semantics.Walk(args[i+1], evalFunc)
+			return value
+		}
+	}
+
+	if len(args)%2 == 1 {
+		value, _ := semantics.Walk(args[len(args)-1], evalFunc)
+		return value
+	}
+	return nil
+}
+
+// Matcher parses the query, and returns a method that checks if an item matches it.
+func (c *Config) Matcher() (func(item unstructured.Unstructured) bool, error) {
+	tree, err := c.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	evalFunctionFactory, err := c.evalFunctionFactory()
+	if err != nil {
+		return nil, err
+	}
+	return matcher(tree, evalFunctionFactory), nil
+}
+
+// Evaluator parses the query as an expression, and returns a method that evaluates it for an item.
+func (c *Config) Evaluator() (func(item unstructured.Unstructured) (interface{}, error), error) {
+	tree, err := c.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	evalFunctionFactory, err := c.evalFunctionFactory()
+	if err != nil {
+		return nil, err
+	}
+	return func(item unstructured.Unstructured) (interface{}, error) {
+		return semantics.Walk(tree, evalFunctionFactory(item))
+	}, nil
+}
+
+// matcher returns a method that checks if an item matches a search tree.
+func matcher(tree *tsl.TSLNode, evalFunctionFactory func(item unstructured.Unstructured) semantics.EvalFunc) func(item unstructured.Unstructured) bool {
+	return func(item unstructured.Unstructured) bool {
+		matchingFilter, err := semantics.Walk(tree, evalFunctionFactory(item))
+		if err != nil {
+			return false
+		}
+		match, ok := matchingFilter.(bool)
+		return ok && match
+	}
+}
+
+// Filter filters items using query.
+func (c *Config) Filter(list []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+	match, err := c.Matcher()
 	if err != nil {
 		return nil, err
 	}
@@ -57,12 +222,7 @@ func (c *Config) Filter(list []unstructured.Unstructured) ([]unstructured.Unstru
 	// Filter items using a query.
 	items := []unstructured.Unstructured{}
 	for _, item := range list {
-		// If we have a query, check item.
-		matchingFilter, err := semantics.Walk(newTree, eval.EvalFunctionFactory(item))
-		if err != nil {
-			continue
-		}
-		if match, ok := matchingFilter.(bool); ok && match {
+		if match(item) {
 			items = append(items, item)
 		}
 	}
@@ -155,3 +155,72 @@ func TestFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterCalls(t *testing.T) {
+	pod := func(name string) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": name, "namespace": "Prod"},
+		}}
+	}
+	items := []unstructured.Unstructured{pod("web-7d4b9c-x2x9z"), pod("web-5f6d7c-abcde"), pod("db-0")}
+
+	calls := map[string]Call{
+		"_call0": {Function: "split_part", Args: []string{"metadata.name", "'-'", "1"}},
+		"_call1": {Function: "lower", Args: []string{"metadata.namespace"}},
+		"_call2": {Function: "concat", Args: []string{"_call1", "'/'", "_call0"}},
+		"_call3": {Function: CaseFunction, Args: []string{"_call0 = 'db'", "'database'", "metadata.name ~= 'abcde$'", "'web'"}},
+		"_call4": {Function: CaseFunction, Args: []string{"metadata.missing > 1", "'no'", "'default'"}},
+		"_call5": {Function: "regexp_replace", Args: []string{"metadata.name", "'-[a-z0-9]+-[a-z0-9]{5}$'", "''"}},
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCount int
+	}{
+		{name: "function in comparison", query: "_call0 = 'web'", wantCount: 2},
+		{name: "nested function calls", query: "_call2 = 'prod/db'", wantCount: 1},
+		{name: "function and field", query: "_call1 = 'prod' and metadata.name ~= '^db'", wantCount: 1},
+		{name: "case first matching condition", query: "_call3 = 'database'", wantCount: 1},
+		{name: "case second matching condition", query: "_call3 = 'web'", wantCount: 1},
+		{name: "case without matching condition", query: "_call3 is null", wantCount: 1},
+		{name: "case else", query: "_call4 = 'default'", wantCount: 3},
+		{name: "constant pattern", query: "_call5 = 'web'", wantCount: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{
+				Query: tt.query,
+				CheckColumnName: func(s string) (string, error) {
+					return s, nil
+				},
+				Calls: calls,
+			}
+
+			got, err := c.Filter(items)
+			if err != nil {
+				t.Fatalf("Filter() error = %v", err)
+			}
+			if len(got) != tt.wantCount {
+				t.Errorf("Filter() got = %v items, want %v", len(got), tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestFilterInvalidPattern(t *testing.T) {
+	c := &Config{
+		Query: "_call0 = 'web'",
+		CheckColumnName: func(s string) (string, error) {
+			return s, nil
+		},
+		Calls: map[string]Call{
+			"_call0": {Function: "regexp_replace", Args: []string{"metadata.name", "'('", "''"}},
+		},
+	}
+
+	if _, err := c.Filter(nil); err == nil {
+		t.Errorf("Filter() error = nil, want invalid pattern error")
+	}
+}
@@ -0,0 +1,177 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/yaacov/kubectl-sql/pkg/eval"
+)
+
+// Column is a column of result rows.
+//
+// Projected rows hold the value of the column query, grouped rows hold a grouping key
+// or the value of an aggregate function.
+type Column struct {
+	Title string
+	// Key is the index of the grouping key of the column, -1 for aggregate columns.
+	Key int
+	// Function is the aggregate function name, e.g. "count".
+	Function string
+	// Query is the projected expression or the aggregate function argument,
+	// empty for "*" as in COUNT(*).
+	Query string
+}
+
+// group is the state of one group of items.
+type group struct {
+	keys        []interface{}
+	aggregators []eval.Aggregator
+}
+
+// Group groups items by the keys queries, and returns one row per group.
+//
+// Rows hold the column values keyed by column title, and are evaluated using
+// eval.RowEvalFunctionFactory. Without keys, all items are aggregated into one row.
+func (c *Config) Group(list []unstructured.Unstructured, keys []string, columns []Column) ([]unstructured.Unstructured, error) {
+	keyEvaluators, err := c.evaluators(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, len(columns))
+	for i, column := range columns {
+		args[i] = column.Query
+	}
+	argEvaluators, err := c.evaluators(args)
+	if err != nil {
+		return nil, err
+	}
+
+	newGroup := func(values []interface{}) (*group, error) {
+		g := &group{keys: values, aggregators: make([]eval.Aggregator, len(columns))}
+		for i, column := range columns {
+			if column.Key >= 0 {
+				continue
+			}
+			if g.aggregators[i], err = eval.NewAggregator(column.Function); err != nil {
+				return nil, err
+			}
+		}
+		return g, nil
+	}
+
+	groups := map[string]*group{}
+	order := []string{}
+
+	// Without grouping keys, aggregate functions return one row even when there are no items.
+	if len(keys) == 0 {
+		g, err := newGroup(nil)
+		if err != nil {
+			return nil, err
+		}
+		groups[""] = g
+		order = append(order, "")
+	}
+
+	for _, item := range list {
+		values := make([]interface{}, len(keys))
+		for i, evaluate := range keyEvaluators {
+			// Keys that fail to evaluate are grouped as missing values.
+			values[i], _ = evaluate(item)
+		}
+
+		id := valuesKey(values)
+		g, ok := groups[id]
+		if !ok {
+			if g, err = newGroup(values); err != nil {
+				return nil, err
+			}
+			groups[id] = g
+			order = append(order, id)
+		}
+
+		for i, aggregator := range g.aggregators {
+			if aggregator == nil {
+				continue
+			}
+
+			var value interface{} = true
+			if argEvaluators[i] != nil {
+				value, _ = argEvaluators[i](item)
+			}
+			if err := aggregator.Add(value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	kind := listKind(list)
+	if kind == "" {
+		kind = c.Kind
+	}
+	rows := make([]unstructured.Unstructured, 0, len(order))
+	for _, id := range order {
+		g := groups[id]
+		object := map[string]interface{}{
+			"kind": kind,
+		}
+		for i, column := range columns {
+			if column.Key >= 0 {
+				object[column.Title] = g.keys[column.Key]
+			} else {
+				object[column.Title] = g.aggregators[i].Result()
+			}
+		}
+		rows = append(rows, unstructured.Unstructured{Object: object})
+	}
+
+	return rows, nil
+}
+
+// valuesKey returns a string identifying a list of values.
+func valuesKey(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = fmt.Sprintf("%T:%v", value, value)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// listKind returns the kind of the items of a list, or a comma separated
+// list of kinds for items of different kinds.
+func listKind(list []unstructured.Unstructured) string {
+	kinds := []string{}
+	seen := map[string]bool{}
+	for _, item := range list {
+		if kind := item.GetKind(); !seen[kind] {
+			seen[kind] = true
+			kinds = append(kinds, kind)
+		}
+	}
+	return strings.Join(kinds, ",")
+}
+
+// evaluators compiles a list of queries, empty queries have nil evaluators.
+func (c *Config) evaluators(queries []string) ([]func(item unstructured.Unstructured) (interface{}, error), error) {
+	evaluators := make([]func(item unstructured.Unstructured) (interface{}, error), len(queries))
+	for i, q := range queries {
+		if q == "" {
+			continue
+		}
+
+		e := Config{
+			CheckColumnName:     c.CheckColumnName,
+			Query:               q,
+			EvalFunctionFactory: c.EvalFunctionFactory,
+			Calls:               c.Calls,
+		}
+		evaluator, err := e.Evaluator()
+		if err != nil {
+			return nil, err
+		}
+		evaluators[i] = evaluator
+	}
+	return evaluators, nil
+}
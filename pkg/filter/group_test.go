@@ -0,0 +1,116 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/yaacov/kubectl-sql/pkg/eval"
+)
+
+func TestGroup(t *testing.T) {
+	pvc := func(namespace, storage string) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"kind":     "PersistentVolumeClaim",
+			"metadata": map[string]interface{}{"name": "data", "namespace": namespace},
+			"spec": map[string]interface{}{
+				"resources": map[string]interface{}{
+					"requests": map[string]interface{}{"storage": storage},
+				},
+			},
+		}}
+	}
+	items := []unstructured.Unstructured{pvc("prod", "1Gi"), pvc("dev", "512Mi"), pvc("prod", "3Gi")}
+	gi := float64(1024 * 1024 * 1024)
+
+	tests := []struct {
+		name    string
+		keys    []string
+		columns []Column
+		want    [][]interface{}
+		wantErr bool
+	}{
+		{
+			name: "group by namespace",
+			keys: []string{"namespace"},
+			columns: []Column{
+				{Title: "namespace", Key: 0},
+				{Title: "count", Key: -1, Function: "count"},
+				{Title: "storage", Key: -1, Function: "sum", Query: "spec.resources.requests.storage"},
+			},
+			want: [][]interface{}{{"prod", float64(2), 4 * gi}, {"dev", float64(1), gi / 2}},
+		},
+		{
+			name: "aggregate without keys",
+			columns: []Column{
+				{Title: "min", Key: -1, Function: "min", Query: "spec.resources.requests.storage"},
+				{Title: "max", Key: -1, Function: "max", Query: "spec.resources.requests.storage"},
+				{Title: "avg", Key: -1, Function: "avg", Query: "spec.resources.requests.storage"},
+			},
+			want: [][]interface{}{{gi / 2, 3 * gi, 1.5 * gi}},
+		},
+		{
+			name:    "sum of strings",
+			columns: []Column{{Title: "sum", Key: -1, Function: "sum", Query: "namespace"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{
+				CheckColumnName: func(s string) (string, error) {
+					return s, nil
+				},
+			}
+
+			rows, err := c.Group(items, tt.keys, tt.columns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Group() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := [][]interface{}{}
+			for _, row := range rows {
+				values := []interface{}{}
+				for _, column := range tt.columns {
+					value, _ := eval.RowEvalFunctionFactory(row)(column.Title)
+					values = append(values, value)
+				}
+				got = append(got, values)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Group() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupEmptyList(t *testing.T) {
+	c := &Config{
+		CheckColumnName: func(s string) (string, error) {
+			return s, nil
+		},
+		Kind: "Pod",
+	}
+	columns := []Column{{Title: "count", Key: -1, Function: "count"}}
+
+	rows, err := c.Group(nil, nil, columns)
+	if err != nil {
+		t.Fatalf("Group() error = %v", err)
+	}
+	if len(rows) != 1 || rows[0].Object["count"] != float64(0) || rows[0].GetKind() != "Pod" {
+		t.Errorf("Group() = %v, want one Pod row with count 0", rows)
+	}
+
+	rows, err = c.Group(nil, []string{"namespace"}, columns)
+	if err != nil {
+		t.Fatalf("Group() error = %v", err)
+	}
+	if len(rows) != 0 {
+		t.Errorf("Group() = %v, want no rows", rows)
+	}
+}
@@ -0,0 +1,140 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/yaacov/tree-search-language/v6/pkg/tsl"
+
+	"github.com/yaacov/kubectl-sql/pkg/eval"
+)
+
+// Join joins two item lists, keeping pairs of items that match the query.
+//
+// Joined items hold the left and right items keyed by their table aliases, if outer
+// is true, left items that match no right item are kept with an empty right side.
+func (c *Config) Join(left, right []unstructured.Unstructured, leftAlias, rightAlias string, outer bool) ([]unstructured.Unstructured, error) {
+	aliases := []string{leftAlias, rightAlias}
+	evalFactory := c.EvalFunctionFactory
+	if evalFactory == nil {
+		evalFactory = eval.JoinedEvalFunctionFactory(aliases)
+	}
+
+	tree, err := c.compile()
+	if err != nil {
+		return nil, err
+	}
+	if evalFactory, err = c.bindCalls(evalFactory); err != nil {
+		return nil, err
+	}
+	match := matcher(tree, evalFactory)
+
+	kind := joinedKind(left, right)
+	joined := func(l, r *unstructured.Unstructured) unstructured.Unstructured {
+		return eval.JoinedItem(kind, aliases, []*unstructured.Unstructured{l, r})
+	}
+
+	// Index right items by join key when the query is an equality of
+	// the two sides, e.g. "p.spec.nodeName = n.name".
+	candidates := func(l *unstructured.Unstructured) []int {
+		all := make([]int, len(right))
+		for i := range right {
+			all[i] = i
+		}
+		return all
+	}
+	if leftKey, rightKey, ok := equiJoinKeys(tree, leftAlias, rightAlias); ok {
+		index := map[string][]int{}
+		for i := range right {
+			if key, ok := joinKey(evalFactory(joined(nil, &right[i])), rightKey); ok {
+				index[key] = append(index[key], i)
+			}
+		}
+
+		candidates = func(l *unstructured.Unstructured) []int {
+			key, ok := joinKey(evalFactory(joined(l, nil)), leftKey)
+			if !ok {
+				return nil
+			}
+			return index[key]
+		}
+	}
+
+	items := []unstructured.Unstructured{}
+	for i := range left {
+		matched := false
+		for _, j := range candidates(&left[i]) {
+			item := joined(&left[i], &right[j])
+			if match(item) {
+				items = append(items, item)
+				matched = true
+			}
+		}
+
+		if outer && !matched {
+			items = append(items, joined(&left[i], nil))
+		}
+	}
+
+	return items, nil
+}
+
+// joinedKind returns the kind of joined items, e.g. "Pod,Node".
+func joinedKind(left, right []unstructured.Unstructured) string {
+	kinds := []string{}
+	for _, list := range [][]unstructured.Unstructured{left, right} {
+		if len(list) > 0 {
+			kinds = append(kinds, list[0].GetKind())
+		}
+	}
+	return strings.Join(kinds, ",")
+}
+
+// equiJoinKeys checks if a search tree is an equality between an identifier
+// of the left table and an identifier of the right table, and returns them.
+func equiJoinKeys(tree *tsl.TSLNode, leftAlias, rightAlias string) (string, string, bool) {
+	if tree.Type() != tsl.KindBinaryExpr {
+		return "", "", false
+	}
+
+	op := tree.Value().(tsl.TSLExpressionOp)
+	if op.Operator != tsl.OpEQ || op.Left.Type() != tsl.KindIdentifier || op.Right.Type() != tsl.KindIdentifier {
+		return "", "", false
+	}
+
+	a := op.Left.Value().(string)
+	b := op.Right.Value().(string)
+	switch {
+	case strings.HasPrefix(a, leftAlias+".") && strings.HasPrefix(b, rightAlias+"."):
+		return a, b, true
+	case strings.HasPrefix(a, rightAlias+".") && strings.HasPrefix(b, leftAlias+"."):
+		return b, a, true
+	}
+	return "", "", false
+}
+
+// joinKey evaluates a join key, missing and array values can not be used as keys.
+// Numbers are keyed as float64, so that equal numbers of different types match,
+// e.g. int64(80) and float64(80).
+func joinKey(evalFunc func(string) (interface{}, bool), key string) (string, bool) {
+	value, ok := evalFunc(key)
+	if !ok || value == nil {
+		return "", false
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		return "", false
+	case int:
+		value = float64(v)
+	case int32:
+		value = float64(v)
+	case int64:
+		value = float64(v)
+	case float32:
+		value = float64(v)
+	}
+	return fmt.Sprintf("%T:%v", value, value), true
+}
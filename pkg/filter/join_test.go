@@ -0,0 +1,136 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/yaacov/tree-search-language/v6/pkg/walkers/semantics"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/yaacov/kubectl-sql/pkg/eval"
+)
+
+func TestJoin(t *testing.T) {
+	pod := func(name, node string) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"kind":     "Pod",
+			"metadata": map[string]interface{}{"name": name, "namespace": "default"},
+			"spec":     map[string]interface{}{"nodeName": node},
+		}}
+	}
+	node := func(name, zone string) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"kind": "Node",
+			"metadata": map[string]interface{}{
+				"name":   name,
+				"labels": map[string]interface{}{"zone": zone},
+			},
+		}}
+	}
+
+	pods := []unstructured.Unstructured{pod("web", "node-a"), pod("db", "node-b"), pod("pending", "")}
+	nodes := []unstructured.Unstructured{node("node-a", "east"), node("node-b", "west")}
+
+	tests := []struct {
+		name      string
+		on        string
+		outer     bool
+		wantZones []interface{}
+	}{
+		{
+			name:      "inner equi join",
+			on:        "p.spec.nodeName = n.name",
+			wantZones: []interface{}{"east", "west"},
+		},
+		{
+			name:      "inner equi join, reversed sides",
+			on:        "n.name = p.spec.nodeName",
+			wantZones: []interface{}{"east", "west"},
+		},
+		{
+			name:      "left join keeps unmatched items",
+			on:        "p.spec.nodeName = n.name",
+			outer:     true,
+			wantZones: []interface{}{"east", "west", nil},
+		},
+		{
+			name:      "non equality condition",
+			on:        "p.spec.nodeName != n.name and n.labels.zone = 'east'",
+			wantZones: []interface{}{"east", "east"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{
+				Query: tt.on,
+				CheckColumnName: func(s string) (string, error) {
+					return s, nil
+				},
+			}
+
+			got, err := c.Join(pods, nodes, "p", "n", tt.outer)
+			if err != nil {
+				t.Fatalf("Join() error = %v", err)
+			}
+			if len(got) != len(tt.wantZones) {
+				t.Fatalf("Join() got = %v items, want %v", len(got), len(tt.wantZones))
+			}
+
+			evalFactory := eval.JoinedEvalFunctionFactory([]string{"p", "n"})
+			for i, item := range got {
+				if item.GetKind() != "Pod,Node" {
+					t.Errorf("Join() kind = %v, want Pod,Node", item.GetKind())
+				}
+
+				zone, _ := evalFactory(item)("n.labels.zone")
+				if zone != tt.wantZones[i] {
+					t.Errorf("Join() item %d zone = %v, want %v", i, zone, tt.wantZones[i])
+				}
+			}
+		})
+	}
+}
+
+func TestJoinNumericKeys(t *testing.T) {
+	service := func(name string, port interface{}) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"kind":     "Service",
+			"metadata": map[string]interface{}{"name": name},
+			"spec":     map[string]interface{}{"port": port},
+		}}
+	}
+
+	// Rows of named queries may hold integer values, while values of kubernetes
+	// resources are evaluated as float64.
+	aliases := []string{"s", "t"}
+	joinedEval := eval.JoinedEvalFunctionFactory(aliases)
+	c := &Config{
+		Query: "s.spec.port = t.spec.port",
+		CheckColumnName: func(s string) (string, error) {
+			return s, nil
+		},
+		EvalFunctionFactory: func(item unstructured.Unstructured) semantics.EvalFunc {
+			evalFunc := joinedEval(item)
+			return func(key string) (interface{}, bool) {
+				if s, ok := item.Object["s"].(map[string]interface{}); ok && key == "s.spec.port" {
+					port, _, _ := unstructured.NestedFieldNoCopy(s, "spec", "port")
+					return port, true
+				}
+				return evalFunc(key)
+			}
+		},
+	}
+
+	left := []unstructured.Unstructured{service("http", int64(80)), service("https", int64(443))}
+	right := []unstructured.Unstructured{service("web", float64(80)), service("dns", float64(53))}
+	got, err := c.Join(left, right, "s", "t", false)
+	if err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("Join() got = %v items, want 1", len(got))
+	}
+	if name, _ := joinedEval(got[0])("t.name"); name != "web" {
+		t.Errorf("Join() name = %v, want web", name)
+	}
+}
@@ -0,0 +1,61 @@
+package filter
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Project evaluates the column queries for each item, and returns one row per item.
+//
+// Rows hold the column values keyed by column title, and are evaluated using
+// eval.RowEvalFunctionFactory. Rows keep the kind and name of their item, e.g. to
+// print them in name format.
+func (c *Config) Project(list []unstructured.Unstructured, columns []Column) ([]unstructured.Unstructured, error) {
+	queries := make([]string, len(columns))
+	for i, column := range columns {
+		queries[i] = column.Query
+	}
+	evaluators, err := c.evaluators(queries)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]unstructured.Unstructured, 0, len(list))
+	for _, item := range list {
+		object := map[string]interface{}{
+			"kind": item.GetKind(),
+		}
+		for i, column := range columns {
+			// Columns that fail to evaluate are missing values.
+			object[column.Title], _ = evaluators[i](item)
+		}
+
+		row := unstructured.Unstructured{Object: object}
+		if name := item.GetName(); name != "" {
+			row.SetName(name)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// Distinct removes rows with the same values in the titled columns, keeping the first row.
+func Distinct(rows []unstructured.Unstructured, titles []string) []unstructured.Unstructured {
+	seen := map[string]bool{}
+	distinct := []unstructured.Unstructured{}
+
+	for _, row := range rows {
+		values := make([]interface{}, len(titles))
+		for i, title := range titles {
+			values[i] = row.Object[title]
+		}
+
+		key := valuesKey(values)
+		if !seen[key] {
+			seen[key] = true
+			distinct = append(distinct, row)
+		}
+	}
+
+	return distinct
+}
@@ -0,0 +1,143 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestProjectDistinct(t *testing.T) {
+	pod := func(name, node string, images ...interface{}) unstructured.Unstructured {
+		containers := []interface{}{}
+		for _, image := range images {
+			containers = append(containers, map[string]interface{}{"image": image})
+		}
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"kind":     "Pod",
+			"metadata": map[string]interface{}{"name": name, "namespace": "default"},
+			"spec":     map[string]interface{}{"nodeName": node, "containers": containers},
+		}}
+	}
+	items := []unstructured.Unstructured{
+		pod("a", "node-a", "nginx"),
+		pod("b", "node-a", "nginx"),
+		pod("c", "node-b", "redis", "nginx"),
+		pod("d", "", "nginx"),
+	}
+
+	tests := []struct {
+		name    string
+		columns []Column
+		want    [][]interface{}
+	}{
+		{
+			name:    "distinct arrays",
+			columns: []Column{{Title: "images", Query: "spec.containers[*].image"}},
+			want:    [][]interface{}{{[]interface{}{"nginx"}}, {[]interface{}{"redis", "nginx"}}},
+		},
+		{
+			name: "distinct pairs",
+			columns: []Column{
+				{Title: "node", Query: "spec.nodeName"},
+				{Title: "namespace", Query: "namespace"},
+			},
+			want: [][]interface{}{{"node-a", "default"}, {"node-b", "default"}, {nil, "default"}},
+		},
+		{
+			name:    "unique names",
+			columns: []Column{{Title: "name", Query: "name"}},
+			want:    [][]interface{}{{"a"}, {"b"}, {"c"}, {"d"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{
+				CheckColumnName: func(s string) (string, error) {
+					return s, nil
+				},
+			}
+
+			rows, err := c.Project(items, tt.columns)
+			if err != nil {
+				t.Fatalf("Project() error = %v", err)
+			}
+			if len(rows) != len(items) {
+				t.Fatalf("Project() got %d rows, want %d", len(rows), len(items))
+			}
+
+			titles := []string{}
+			for _, column := range tt.columns {
+				titles = append(titles, column.Title)
+			}
+
+			got := [][]interface{}{}
+			for _, row := range Distinct(rows, titles) {
+				values := []interface{}{}
+				for _, title := range titles {
+					values = append(values, row.Object[title])
+				}
+				got = append(got, values)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Distinct() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectKinds(t *testing.T) {
+	item := func(kind, name string) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"kind":     kind,
+			"metadata": map[string]interface{}{"name": name},
+		}}
+	}
+	items := []unstructured.Unstructured{item("Deployment", "web"), item("StatefulSet", "db"), item("Deployment", "api")}
+
+	c := &Config{
+		CheckColumnName: func(s string) (string, error) {
+			return s, nil
+		},
+	}
+	rows, err := c.Project(items, []Column{{Title: "name", Query: "metadata.name"}})
+	if err != nil {
+		t.Fatalf("Project() error = %v", err)
+	}
+
+	got := []string{}
+	for _, row := range rows {
+		got = append(got, row.GetKind()+"/"+row.Object["name"].(string))
+	}
+	want := []string{"Deployment/web", "StatefulSet/db", "Deployment/api"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Project() = %v, want %v", got, want)
+	}
+
+	if got := listKind(items); got != "Deployment,StatefulSet" {
+		t.Errorf("listKind() = %q, want %q", got, "Deployment,StatefulSet")
+	}
+}
+
+func TestProjectNames(t *testing.T) {
+	items := []unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"kind":     "Pod",
+			"metadata": map[string]interface{}{"name": "web", "namespace": "default"},
+		}},
+	}
+
+	c := &Config{
+		CheckColumnName: func(s string) (string, error) {
+			return s, nil
+		},
+	}
+	rows, err := c.Project(items, []Column{{Title: "namespace", Query: "namespace"}})
+	if err != nil {
+		t.Fatalf("Project() error = %v", err)
+	}
+	if len(rows) != 1 || rows[0].GetName() != "web" || rows[0].Object["namespace"] != "default" {
+		t.Errorf("Project() = %v, want a row of web with namespace default", rows)
+	}
+}
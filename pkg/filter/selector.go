@@ -0,0 +1,195 @@
+package filter
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/yaacov/tree-search-language/v6/pkg/tsl"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/yaacov/kubectl-sql/pkg/eval"
+)
+
+// Requirement is a condition of the query that the server can check when listing items,
+// e.g. labels.app = 'nginx' is the label selector requirement app=nginx. Items are still
+// filtered by the query, requirements only narrow the listed items.
+type Requirement struct {
+	// Field is true for field selector requirements, and false for label selector requirements.
+	Field bool
+	Key   string
+	// Operator is the selector operator, one of "=", "!=", "in", "notin", "exists" or "!".
+	Operator string
+	Values   []string
+}
+
+// String returns the selector syntax of a requirement, e.g. app in (web,db) or !app.
+func (r Requirement) String() string {
+	switch r.Operator {
+	case "in", "notin":
+		return r.Key + " " + r.Operator + " (" + strings.Join(r.Values, ",") + ")"
+	case "exists":
+		return r.Key
+	case "!":
+		return "!" + r.Key
+	default:
+		return r.Key + r.Operator + strings.Join(r.Values, "")
+	}
+}
+
+// valid checks if the server accepts a requirement, e.g. label selectors must use valid label keys and values.
+func (r Requirement) valid() bool {
+	var err error
+	if r.Field {
+		_, err = fields.ParseSelector(r.String())
+	} else {
+		_, err = labels.Parse(r.String())
+	}
+	return err == nil
+}
+
+// fieldPathPattern matches the paths of fields a field selector may use, e.g. spec.nodeName or type,
+// resources are selected by a few fields, that are different for each kind.
+var fieldPathPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9]*(\.[a-zA-Z][a-zA-Z0-9]*)*$`)
+
+// Requirements returns the conditions of the query that the server can check, these are
+// the conditions on labels and fields that are AND-ed at the top level of the query. Field
+// requirements may use fields the server can not select a resource by, and should be
+// narrowed to the selectable fields of the listed resource.
+func (c *Config) Requirements() ([]Requirement, error) {
+	tree, err := c.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	requirements := []Requirement{}
+	for _, node := range conjuncts(tree) {
+		if r, ok := requirement(node); ok && r.valid() {
+			requirements = append(requirements, r)
+		}
+	}
+	return requirements, nil
+}
+
+// Selectors returns the label and field selectors of a list of requirements.
+func Selectors(requirements []Requirement) (string, string) {
+	labelRequirements, fieldRequirements := []string{}, []string{}
+	for _, r := range requirements {
+		if r.Field {
+			fieldRequirements = append(fieldRequirements, r.String())
+		} else {
+			labelRequirements = append(labelRequirements, r.String())
+		}
+	}
+	return strings.Join(labelRequirements, ","), strings.Join(fieldRequirements, ",")
+}
+
+// conjuncts returns the AND-ed conditions at the top level of a search tree.
+func conjuncts(tree *tsl.TSLNode) []*tsl.TSLNode {
+	if tree.Type() != tsl.KindBinaryExpr {
+		return []*tsl.TSLNode{tree}
+	}
+
+	op := tree.Value().(tsl.TSLExpressionOp)
+	if op.Operator != tsl.OpAnd {
+		return []*tsl.TSLNode{tree}
+	}
+	return append(conjuncts(op.Left), conjuncts(op.Right)...)
+}
+
+// requirement returns the selector requirement of a condition, e.g. labels.app in ['web', 'db'],
+// labels.app is not null, or name = 'web'.
+func requirement(node *tsl.TSLNode) (Requirement, bool) {
+	negated := false
+	if node.Type() == tsl.KindUnaryExpr {
+		op := node.Value().(tsl.TSLExpressionOp)
+		if op.Operator != tsl.OpNot {
+			return Requirement{}, false
+		}
+		negated, node = true, op.Right
+	}
+	if node.Type() != tsl.KindBinaryExpr {
+		return Requirement{}, false
+	}
+
+	op := node.Value().(tsl.TSLExpressionOp)
+	if op.Left.Type() != tsl.KindIdentifier {
+		return Requirement{}, false
+	}
+	key, field, ok := selectorKey(op.Left.Value().(string))
+	if !ok {
+		return Requirement{}, false
+	}
+	r := Requirement{Field: field, Key: key}
+
+	switch {
+	case op.Operator == tsl.OpEQ && !negated:
+		r.Operator = "="
+	case op.Operator == tsl.OpNE && !negated:
+		r.Operator = "!="
+	case op.Operator == tsl.OpIn && !field:
+		r.Operator = "in"
+		if negated {
+			r.Operator = "notin"
+		}
+	case op.Operator == tsl.OpIs && !field && op.Right.Type() == tsl.KindNullLiteral:
+		r.Operator = "!"
+		if negated {
+			r.Operator = "exists"
+		}
+		return r, true
+	default:
+		return Requirement{}, false
+	}
+
+	values, ok := selectorValues(op.Right)
+	if !ok || (r.Operator != "in" && r.Operator != "notin" && len(values) != 1) {
+		return Requirement{}, false
+	}
+	r.Values = values
+	return r, true
+}
+
+// selectorKey returns the label key, or the field path, of a query identifier, e.g. app
+// for labels.app, app.kubernetes.io/name for labels['app.kubernetes.io/name'] and spec.nodeName.
+func selectorKey(s string) (string, bool, bool) {
+	if fieldPathPattern.MatchString(s) && !strings.HasPrefix(s, "labels.") && !strings.HasPrefix(s, "annotations.") {
+		return s, true, true
+	}
+
+	key := ""
+	switch {
+	case strings.HasPrefix(s, "labels."):
+		key = s[len("labels."):]
+	case strings.HasPrefix(s, "labels['") && strings.HasSuffix(s, "']"):
+		key = s[len("labels['") : len(s)-2]
+	}
+	if key == "" || strings.ContainsAny(key, "'[]*? ") {
+		return "", false, false
+	}
+	return key, false, true
+}
+
+// selectorValues returns the values of a string literal, or a list of string literals.
+//
+// Values of labels and fields are typed when compared, e.g. "3" is a number, only values that
+// are compared as strings select the same items on the server, and are used in selectors.
+func selectorValues(node *tsl.TSLNode) ([]string, bool) {
+	nodes := []*tsl.TSLNode{node}
+	if node.Type() == tsl.KindArrayLiteral {
+		nodes = node.Value().(tsl.TSLArrayLiteral).Values
+	}
+
+	values := []string{}
+	for _, n := range nodes {
+		if n.Type() != tsl.KindStringLiteral {
+			return nil, false
+		}
+		s := n.Value().(string)
+		if _, ok := eval.InferValue(s).(string); !ok || s == "" || strings.ContainsAny(s, ",()! =") {
+			return nil, false
+		}
+		values = append(values, s)
+	}
+	return values, len(values) > 0
+}
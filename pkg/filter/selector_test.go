@@ -0,0 +1,70 @@
+package filter
+
+import (
+	"testing"
+)
+
+func TestSelectors(t *testing.T) {
+	aliases := map[string]string{"name": "metadata.name", "phase": "status.phase"}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantLabels string
+		wantFields string
+	}{
+		{name: "label equals", query: "labels.app = 'web'", wantLabels: "app=web"},
+		{name: "label not equals", query: "labels.app != 'web'", wantLabels: "app!=web"},
+		{name: "label in", query: "labels.app in ['web', 'db']", wantLabels: "app in (web,db)"},
+		{name: "label not in", query: "labels.app not in ['web', 'db']", wantLabels: "app notin (web,db)"},
+		{name: "label is null", query: "labels.app is null", wantLabels: "!app"},
+		{name: "label is not null", query: "labels.app is not null", wantLabels: "app"},
+		{name: "quoted label key", query: "labels['app.kubernetes.io/name'] = 'web'", wantLabels: "app.kubernetes.io/name=web"},
+		{name: "aliased name", query: "name = 'web-0'", wantFields: "metadata.name=web-0"},
+		{
+			name:       "conjuncts",
+			query:      "labels.app = 'web' and (phase = 'Running' and labels.tier is not null) and metadata.namespace != 'test'",
+			wantLabels: "app=web,tier",
+			wantFields: "status.phase=Running,metadata.namespace!=test",
+		},
+		{name: "disjunction", query: "labels.app = 'web' or labels.app = 'db'"},
+		{name: "negated equality", query: "not (labels.app = 'web')"},
+		{name: "typed value", query: "labels.version = '3'"},
+		{name: "number", query: "labels.version = 3"},
+		{name: "regular expression", query: "labels.app ~= '^web'"},
+		{name: "field in", query: "name in ['web-0', 'web-1']"},
+		{name: "aliased field", query: "phase = 'Running'", wantFields: "status.phase=Running"},
+		{name: "top level field", query: "type = 'Warning' and reason != 'Pulled'", wantFields: "type=Warning,reason!=Pulled"},
+		{name: "placeholder", query: "_subquery0 = 'x'"},
+		{name: "array field", query: "spec.containers[0].image = 'nginx'"},
+		{name: "annotation", query: "annotations.owner = 'me'"},
+		{name: "invalid label value", query: "labels.app = '-web' and labels.tier = 'db'", wantLabels: "tier=db"},
+		{name: "invalid label key", query: "labels['app/x/y'] is null"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{
+				Query: tt.query,
+				CheckColumnName: func(s string) (string, error) {
+					if v, ok := aliases[s]; ok {
+						return v, nil
+					}
+					return s, nil
+				},
+			}
+
+			requirements, err := c.Requirements()
+			if err != nil {
+				t.Fatalf("Requirements() error = %v", err)
+			}
+			labels, fields := Selectors(requirements)
+			if labels != tt.wantLabels {
+				t.Errorf("Selectors() labels = %q, want %q", labels, tt.wantLabels)
+			}
+			if fields != tt.wantFields {
+				t.Errorf("Selectors() fields = %q, want %q", fields, tt.wantFields)
+			}
+		})
+	}
+}
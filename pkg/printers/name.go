@@ -25,10 +25,17 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
-// Name prints items in Name format
+// Name prints items in Name format, rows projected from an item print the name of the item,
+// other rows, e.g. joined items, print the value of their name key.
 func (c *Config) Name(items []unstructured.Unstructured) error {
 	for _, item := range items {
-		fmt.Fprintf(c.Out, "%s\n", item.GetName())
+		if name := item.GetName(); name != "" {
+			fmt.Fprintf(c.Out, "%s\n", name)
+			continue
+		}
+
+		name, _ := c.evalFunction(item)("name")
+		fmt.Fprintf(c.Out, "%v\n", name)
 	}
 
 	return nil
@@ -19,10 +19,168 @@ Author: 2020 Yaacov Zamir <kobi.zamir@gmail.com>
 
 package printers
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 // OrderByField represents a field to order by in SQL query results
 type OrderByField struct {
 	// Name is the field name to sort by
 	Name string
 	// Descending indicates whether to sort in descending order (true) or ascending (false)
 	Descending bool
+	// Nulls sets the position of missing values
+	Nulls Nulls
+	// Collation sets how strings are compared
+	Collation Collation
+}
+
+// Nulls is the position of missing values in sorted results.
+type Nulls int
+
+const (
+	// NullsDefault sorts missing values before other values, first in ascending
+	// order and last in descending order
+	NullsDefault Nulls = iota
+	// NullsFirst sorts missing values first
+	NullsFirst
+	// NullsLast sorts missing values last
+	NullsLast
+)
+
+// Collation is the way strings are compared when sorting results.
+type Collation int
+
+const (
+	// CollateBinary compares strings byte by byte
+	CollateBinary Collation = iota
+	// CollateNoCase compares strings case insensitive
+	CollateNoCase
+	// CollateNatural compares strings case insensitive, and numbers in strings
+	// by value, e.g. "pod-2" before "pod-10"
+	CollateNatural
+)
+
+// compare compares the values of two items, returning a negative number if a is
+// sorted before b, a positive number if a is sorted after b, and zero otherwise.
+func (f OrderByField) compare(a, b interface{}) int {
+	// Missing values are placed by Nulls, regardless of the sort direction.
+	if a == nil || b == nil {
+		if a == nil && b == nil {
+			return 0
+		}
+
+		nullsFirst := f.Nulls == NullsFirst || (f.Nulls == NullsDefault && !f.Descending)
+		if (a == nil) == nullsFirst {
+			return -1
+		}
+		return 1
+	}
+
+	c := compareValues(a, b, f.Collation)
+	if f.Descending {
+		return -c
+	}
+	return c
+}
+
+// compareValues compares two values, values of different types are ordered by type,
+// booleans before numbers, dates and strings.
+func compareValues(a, b interface{}, collation Collation) int {
+	if ra, rb := typeRank(a), typeRank(b); ra != rb {
+		return ra - rb
+	}
+
+	switch va := a.(type) {
+	case bool:
+		vb := b.(bool)
+		switch {
+		case va == vb:
+			return 0
+		case va:
+			// true sorts before false in ascending order.
+			return -1
+		}
+		return 1
+	case float64:
+		vb := b.(float64)
+		switch {
+		case va < vb:
+			return -1
+		case va > vb:
+			return 1
+		}
+		return 0
+	case time.Time:
+		return va.Compare(b.(time.Time))
+	case string:
+		return compareStrings(va, b.(string), collation)
+	default:
+		return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+	}
+}
+
+// typeRank returns the order of the type of a value.
+func typeRank(v interface{}) int {
+	switch v.(type) {
+	case bool:
+		return 0
+	case float64:
+		return 1
+	case time.Time:
+		return 2
+	case string:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// compareStrings compares two strings using a collation.
+func compareStrings(a, b string, collation Collation) int {
+	switch collation {
+	case CollateNoCase:
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	case CollateNatural:
+		return compareNatural(strings.ToLower(a), strings.ToLower(b))
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// compareNatural compares strings in natural order, runs of digits are compared
+// by their numeric value, e.g. "pod-2" is before "pod-10".
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		na, nb := digitsPrefix(a), digitsPrefix(b)
+		if na == 0 || nb == 0 {
+			if a[0] != b[0] {
+				return strings.Compare(a[:1], b[:1])
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		// Compare numbers without leading zeros by length, then digit by digit.
+		da, db := strings.TrimLeft(a[:na], "0"), strings.TrimLeft(b[:nb], "0")
+		if len(da) != len(db) {
+			return len(da) - len(db)
+		}
+		if c := strings.Compare(da, db); c != 0 {
+			return c
+		}
+		a, b = a[na:], b[nb:]
+	}
+	return len(a) - len(b)
+}
+
+// digitsPrefix returns the number of digits at the start of a string.
+func digitsPrefix(s string) int {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return n
 }
@@ -0,0 +1,30 @@
+package printers
+
+import "testing"
+
+func TestOrderByFieldCompare(t *testing.T) {
+	tests := []struct {
+		name  string
+		field OrderByField
+		a, b  interface{}
+		want  int
+	}{
+		{name: "true before false", field: OrderByField{}, a: true, b: false, want: -1},
+		{name: "false after true", field: OrderByField{}, a: false, b: true, want: 1},
+		{name: "descending booleans", field: OrderByField{Descending: true}, a: true, b: false, want: 1},
+		{name: "numbers", field: OrderByField{}, a: 1.0, b: 2.0, want: -1},
+		{name: "booleans before numbers", field: OrderByField{}, a: 2.0, b: true, want: 1},
+		{name: "nulls first", field: OrderByField{}, a: nil, b: "a", want: -1},
+		{name: "nulls last", field: OrderByField{Nulls: NullsLast}, a: nil, b: "a", want: 1},
+		{name: "natural collation", field: OrderByField{Collation: CollateNatural}, a: "pod-2", b: "pod-10", want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.field.compare(tt.a, tt.b)
+			if (got < 0) != (tt.want < 0) || (got > 0) != (tt.want > 0) {
+				t.Errorf("compare(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
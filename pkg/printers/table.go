@@ -22,11 +22,12 @@ package printers
 import (
 	"fmt"
 	"io"
-	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/yaacov/tree-search-language/v6/pkg/walkers/semantics"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	"github.com/yaacov/kubectl-sql/pkg/eval"
@@ -52,6 +53,13 @@ type Config struct {
 	OrderByFields []OrderByField
 	// Limit restricts the number of results displayed (0 means no limit)
 	Limit int
+	// Offset is the number of sorted results to skip before displaying results
+	Offset int
+	// Partial is true if listing stopped once enough results matched, the count of results is a lower bound
+	Partial bool
+	// EvalFunctionFactory builds the key evaluation method for an item,
+	// if nil eval.EvalFunctionFactory is used
+	EvalFunctionFactory func(item unstructured.Unstructured) semantics.EvalFunc
 	// NoHeaders if true, don't print header rows
 	NoHeaders bool
 	// Out think, os.Stdout
@@ -65,6 +73,14 @@ const (
 	SelectedFields = "selected"
 )
 
+// evalFunction returns the key evaluation method for an item.
+func (c *Config) evalFunction(item unstructured.Unstructured) semantics.EvalFunc {
+	if c.EvalFunctionFactory == nil {
+		return eval.EvalFunctionFactory(item)
+	}
+	return c.EvalFunctionFactory(item)
+}
+
 // Get the table column titles and fields for the items.
 func (c *Config) getTableColumns(items []unstructured.Unstructured) tableFields {
 	var evalFunc func(string) (interface{}, bool)
@@ -89,7 +105,7 @@ func (c *Config) getTableColumns(items []unstructured.Unstructured) tableFields
 
 	// Calculte field widths
 	for _, item := range items {
-		evalFunc = eval.EvalFunctionFactory(item)
+		evalFunc = c.evalFunction(item)
 
 		for i, field := range fields {
 			if value, found := evalFunc(field.Name); found && value != nil {
@@ -124,87 +140,92 @@ func (c *Config) sortItems(items []unstructured.Unstructured) {
 		return
 	}
 
-	sort.SliceStable(items, func(i, j int) bool {
-		for _, orderBy := range c.OrderByFields {
-			evalFuncI := eval.EvalFunctionFactory(items[i])
-			evalFuncJ := eval.EvalFunctionFactory(items[j])
-
-			valueI, foundI := evalFuncI(orderBy.Name)
-			valueJ, foundJ := evalFuncJ(orderBy.Name)
-
-			// If either value is not found, prioritize the found value
-			if !foundI && foundJ {
-				return !orderBy.Descending
-			}
-			if foundI && !foundJ {
-				return orderBy.Descending
-			}
-			if !foundI && !foundJ {
-				continue
-			}
-
-			// Both values found, compare them
-			if valueI == nil && valueJ != nil {
-				return !orderBy.Descending
-			}
-			if valueI != nil && valueJ == nil {
-				return orderBy.Descending
+	// Evaluate the sort keys once for each item, missing values are nil.
+	keys := make([][]interface{}, len(items))
+	for i, item := range items {
+		evalFunc := c.evalFunction(item)
+		keys[i] = make([]interface{}, len(c.OrderByFields))
+		for k, orderBy := range c.OrderByFields {
+			if value, found := evalFunc(orderBy.Name); found {
+				keys[i][k] = value
 			}
+		}
+	}
 
-			// Compare values
-			switch vI := valueI.(type) {
-			case bool:
-				vJ := valueJ.(bool)
-				if vI != vJ {
-					return vI != orderBy.Descending
-				}
-			case float64:
-				vJ := valueJ.(float64)
-				if vI != vJ {
-					return vI < vJ != orderBy.Descending
-				}
-			case string:
-				vJ := valueJ.(string)
-				if vI != vJ {
-					return vI < vJ != orderBy.Descending
-				}
-			case time.Time:
-				vJ := valueJ.(time.Time)
-				if !vI.Equal(vJ) {
-					return vI.Before(vJ) != orderBy.Descending
-				}
-			default:
-				// Fallback to reflect.DeepEqual for other types
-				if !reflect.DeepEqual(valueI, valueJ) {
-					return reflect.DeepEqual(valueI, valueJ) != orderBy.Descending
-				}
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		for k, orderBy := range c.OrderByFields {
+			if cmp := orderBy.compare(keys[order[i]][k], keys[order[j]][k]); cmp != 0 {
+				return cmp < 0
 			}
 		}
 		return false
 	})
+
+	sorted := make([]unstructured.Unstructured, len(items))
+	for i, k := range order {
+		sorted[i] = items[k]
+	}
+	copy(items, sorted)
+}
+
+// Window sorts items if OrderByFields is set, and returns the items
+// displayed after skipping Offset items and applying Limit.
+func (c *Config) Window(items []unstructured.Unstructured) []unstructured.Unstructured {
+	c.sortItems(items)
+
+	start := c.Offset
+	if start > len(items) {
+		start = len(items)
+	}
+
+	end := len(items)
+	if c.Limit > 0 && start+c.Limit < end {
+		end = start + c.Limit
+	}
+
+	return items[start:end]
+}
+
+// itemsKind returns the kind of the items, or a comma separated list of kinds
+// for items of different kinds.
+func itemsKind(items []unstructured.Unstructured) string {
+	kinds := []string{}
+	seen := map[string]bool{}
+	for _, item := range items {
+		if kind := item.GetKind(); !seen[kind] {
+			seen[kind] = true
+			kinds = append(kinds, kind)
+		}
+	}
+	return strings.Join(kinds, ",")
 }
 
 // Table prints items in Table format
 func (c *Config) Table(items []unstructured.Unstructured) error {
 	var evalFunc func(string) (interface{}, bool)
 
-	// Sort items if OrderByFields is set
-	c.sortItems(items)
+	// Sort items, and apply offset and limit if set
+	window := c.Window(items)
 
 	// Get table fields for the items.
 	fields := c.getTableColumns(items)
 
-	// Apply limit if set
-	displayCount := len(items)
-	if c.Limit > 0 && c.Limit < displayCount {
-		displayCount = c.Limit
-	}
-
 	// Print table head if headers are not disabled
 	if !c.NoHeaders {
-		fmt.Fprintf(c.Out, "KIND: %s\tCOUNT: %d", items[0].GetKind(), len(items))
-		if c.Limit > 0 && c.Limit < len(items) {
-			fmt.Fprintf(c.Out, "\tDISPLAYING: %d", displayCount)
+		count := fmt.Sprintf("%d", len(items))
+		if c.Partial {
+			count += "+"
+		}
+		fmt.Fprintf(c.Out, "KIND: %s\tCOUNT: %s", itemsKind(items), count)
+		switch {
+		case c.Offset > 0 && len(window) > 0:
+			fmt.Fprintf(c.Out, "\tDISPLAYING: %d-%d", c.Offset+1, c.Offset+len(window))
+		case len(window) < len(items):
+			fmt.Fprintf(c.Out, "\tDISPLAYING: %d", len(window))
 		}
 		fmt.Fprintf(c.Out, "\n")
 
@@ -213,17 +234,12 @@ func (c *Config) Table(items []unstructured.Unstructured) error {
 				fmt.Fprintf(c.Out, field.Template, field.Title)
 			}
 		}
-		fmt.Print("\n")
+		fmt.Fprint(c.Out, "\n")
 	}
 
 	// Print table rows
-	for i, item := range items {
-		// Respect the limit if set
-		if c.Limit > 0 && i >= c.Limit {
-			break
-		}
-
-		evalFunc = eval.EvalFunctionFactory(item)
+	for _, item := range window {
+		evalFunc = c.evalFunction(item)
 
 		for _, field := range fields {
 			if field.Width > 0 {
@@ -239,6 +255,8 @@ func (c *Config) Table(items []unstructured.Unstructured) error {
 						value = strconv.FormatFloat(v, 'f', -1, 64)
 					case time.Time:
 						value = v.Format(time.RFC3339)
+					case []interface{}:
+						value = fmt.Sprintf("%v", v)
 					}
 
 					fmt.Fprintf(c.Out, field.Template, value)
@@ -247,7 +265,7 @@ func (c *Config) Table(items []unstructured.Unstructured) error {
 				}
 			}
 		}
-		fmt.Print("\n")
+		fmt.Fprint(c.Out, "\n")
 	}
 
 	return nil
@@ -0,0 +1,250 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Statement is a parsed SELECT query.
+type Statement struct {
+	// Explain is true for EXPLAIN queries, that print how the query is run instead of its result.
+	Explain bool
+	// With lists the named queries of the WITH clause, that may be used as resources
+	// of the FROM and JOIN clauses.
+	With []CTE
+	// Distinct is true for SELECT DISTINCT queries.
+	Distinct bool
+	// Fields are the selected columns, nil when selecting "*".
+	Fields []Field
+	// From lists the requested resources.
+	From []TableRef
+	// Joins lists the resources joined to the first resource of the FROM clause.
+	Joins []Join
+	// Where is the filter expression, nil if the query has no WHERE clause.
+	Where *Expr
+	// GroupBy lists the grouping expressions.
+	GroupBy []*Expr
+	// Having is the filter expression of aggregated rows, nil if the query has no HAVING clause.
+	Having *Expr
+	// Unions lists the queries combined with this query using UNION [ALL].
+	Unions []Union
+	// OrderBy lists the sort keys, of the combined rows of queries with UNION.
+	OrderBy []OrderItem
+	// Limit is the maximum number of rows to display, 0 means no limit.
+	Limit int
+	// Offset is the number of rows to skip before displaying rows.
+	Offset int
+}
+
+// Union is a query combined with the rows of the previous queries, e.g. UNION ALL SELECT ...
+// ORDER BY, LIMIT and OFFSET clauses of the last query apply to the combined rows, and are
+// set in the first statement.
+type Union struct {
+	// All is true for UNION ALL, and false for UNION that removes duplicate rows.
+	All       bool
+	Statement *Statement
+}
+
+// CTE is a named query of the WITH clause, e.g. WITH unready AS (SELECT ...).
+type CTE struct {
+	Name      string
+	Statement *Statement
+	// Text is the source text of the query, without the parentheses.
+	Text string
+}
+
+// Field is one column of the SELECT list.
+type Field struct {
+	Expr *Expr
+	// Alias is the name given to the column using AS, empty if not set.
+	Alias string
+	// Text is the source text of the expression, e.g. "len(spec.containers)".
+	Text string
+}
+
+// TableRef is a resource in the FROM clause, e.g. "pods" or "*/pods p".
+type TableRef struct {
+	Resource string
+	// Alias is the table name used to qualify field references, empty if not set.
+	Alias string
+}
+
+// Join is a JOIN ... ON clause.
+type Join struct {
+	Table TableRef
+	// Left is true for LEFT JOIN, and false for INNER JOIN.
+	Left bool
+	On   *Expr
+}
+
+// OrderItem is one sort key of the ORDER BY clause, e.g. name COLLATE natural DESC NULLS LAST.
+type OrderItem struct {
+	Expr       *Expr
+	Descending bool
+	// Position is the number of the selected column to sort by, starting at 1, e.g. ORDER BY 2,
+	// 0 for sort keys that are expressions.
+	Position int
+	// Nulls is "FIRST" or "LAST" for NULLS FIRST and NULLS LAST, empty if not set.
+	Nulls string
+	// Collation is the lower case name of the string collation, e.g. "nocase" or "natural",
+	// empty if not set.
+	Collation string
+}
+
+// SyntaxError is a query parsing error with position information.
+type SyntaxError struct {
+	Message  string
+	Position int
+	Input    string
+}
+
+// Error implements the error interface.
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d:\n%s\n%s^",
+		e.Message, e.Position, e.Input, strings.Repeat(" ", e.Position))
+}
+
+// Expr is an expression written in the tree search language (TSL),
+// extended with function calls.
+type Expr struct {
+	Tokens []Token
+}
+
+// Call is a function call, e.g. COUNT(*) or SUM(spec.replicas), a CASE expression or a subquery.
+type Call struct {
+	Name string
+	Args []*Expr
+	// Star is true for calls with a "*" argument, e.g. COUNT(*).
+	Star bool
+	// Case is the parsed CASE expression, nil for function calls.
+	Case *Case
+	// Subquery is the nested SELECT statement of subqueries, e.g. EXISTS (SELECT ...),
+	// nil for function calls.
+	Subquery *Subquery
+}
+
+// Subquery is a SELECT statement nested in an expression, e.g. name IN (SELECT ...).
+type Subquery struct {
+	Statement *Statement
+	// Text is the query text of the nested statement, without parentheses.
+	Text string
+}
+
+// Case is a CASE expression, e.g. CASE WHEN spec.replicas > 1 THEN 'ha' ELSE 'single' END.
+type Case struct {
+	// Operand is the value compared with the WHEN values of a simple CASE
+	// expression, e.g. CASE status.phase WHEN 'Running' THEN ..., nil for
+	// CASE expressions with WHEN conditions.
+	Operand *Expr
+	Whens   []When
+	// Else is the result if no WHEN branch matches, nil if not set.
+	Else *Expr
+}
+
+// When is one WHEN ... THEN ... branch of a CASE expression.
+type When struct {
+	Cond   *Expr
+	Result *Expr
+}
+
+// String renders the CASE expression.
+func (c *Case) String() string {
+	parts := []string{"CASE"}
+	if c.Operand != nil {
+		parts = append(parts, c.Operand.String())
+	}
+	for _, w := range c.Whens {
+		parts = append(parts, "WHEN", w.Cond.String(), "THEN", w.Result.String())
+	}
+	if c.Else != nil {
+		parts = append(parts, "ELSE", c.Else.String())
+	}
+	return strings.Join(append(parts, "END"), " ")
+}
+
+// String renders the function call.
+func (c *Call) String() string {
+	if c.Case != nil {
+		return c.Case.String()
+	}
+	if c.Subquery != nil {
+		return c.Name + "(" + c.Subquery.Text + ")"
+	}
+	if c.Star {
+		return c.Name + "(*)"
+	}
+
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		args[i] = a.String()
+	}
+	return c.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// String renders the expression as TSL text.
+func (e *Expr) String() string {
+	if e == nil {
+		return ""
+	}
+
+	parts := make([]string, len(e.Tokens))
+	for i, t := range e.Tokens {
+		parts[i] = t.Text
+	}
+	return strings.Join(parts, " ")
+}
+
+// TSL renders the expression as TSL text, replacing each function call
+// with the text returned by bind.
+func (e *Expr) TSL(bind func(c *Call) (string, error)) (string, error) {
+	if e == nil {
+		return "", nil
+	}
+
+	parts := make([]string, len(e.Tokens))
+	for i, t := range e.Tokens {
+		if t.Kind != TokenCall {
+			parts[i] = t.Text
+			continue
+		}
+
+		text, err := bind(t.Call)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = text
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// Calls returns the function calls of the expression, not including
+// calls nested in call arguments.
+func (e *Expr) Calls() []*Call {
+	calls := []*Call{}
+	if e == nil {
+		return calls
+	}
+
+	for _, t := range e.Tokens {
+		if t.Kind == TokenCall {
+			calls = append(calls, t.Call)
+		}
+	}
+	return calls
+}
+
+// Call returns the function call if the expression is a single function call.
+func (e *Expr) Call() (*Call, bool) {
+	if e == nil || len(e.Tokens) != 1 || e.Tokens[0].Kind != TokenCall {
+		return nil, false
+	}
+	return e.Tokens[0].Call, true
+}
+
+// Identifier returns the identifier name if the expression is a single identifier.
+func (e *Expr) Identifier() (string, bool) {
+	if e == nil || len(e.Tokens) != 1 || e.Tokens[0].Kind != TokenIdent {
+		return "", false
+	}
+	return e.Tokens[0].Text, true
+}
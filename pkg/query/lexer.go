@@ -0,0 +1,439 @@
+package query
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// TokenKind is the kind of a lexical token.
+type TokenKind int
+
+const (
+	// TokenEOF marks the end of the input.
+	TokenEOF TokenKind = iota
+	// TokenIdent is an identifier or keyword, e.g. name, spec.containers[0].image, SELECT.
+	TokenIdent
+	// TokenString is a quoted string literal.
+	TokenString
+	// TokenNumber is a numeric literal, optionally with an SI suffix, e.g. 42, 1.5, 20Gi.
+	TokenNumber
+	// TokenDate is an unquoted date or RFC3339 timestamp literal.
+	TokenDate
+	// TokenOperator is an operator, e.g. =, !=, <=, ~=, +, *.
+	TokenOperator
+	// TokenPunct is a punctuation mark, e.g. ( ) [ ] , ;
+	TokenPunct
+	// TokenCall is a function call, e.g. COUNT(*), built by the parser from the call tokens.
+	TokenCall
+	// TokenParam is a query parameter, e.g. :ns, replaced by a literal of its bound value.
+	TokenParam
+)
+
+// Token is a lexical token of a query.
+type Token struct {
+	Kind TokenKind
+	// Text is the raw source text of the token, quoted keys of identifiers are rendered
+	// as quoted subscripts, e.g. labels['app.kubernetes.io/name'].
+	Text string
+	// Value is the unquoted value of string tokens, and the text of all other tokens.
+	Value string
+	// Quote is the quote character of string tokens.
+	Quote byte
+	// Pos and End are the byte offsets of the token in the input.
+	Pos int
+	End int
+	// Call is the function call of call tokens.
+	Call *Call
+	// Param is the name of the query parameter bound to a literal token, empty for other tokens.
+	Param string
+}
+
+// Is checks if a token is an identifier matching a keyword, case insensitive.
+func (t Token) Is(keyword string) bool {
+	return t.Kind == TokenIdent && strings.EqualFold(t.Text, keyword)
+}
+
+// IsPunct checks if a token is a specific punctuation mark or operator.
+func (t Token) IsPunct(s string) bool {
+	return (t.Kind == TokenPunct || t.Kind == TokenOperator) && t.Text == s
+}
+
+var (
+	datePattern    = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+	rfc3339Pattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+)
+
+// lexer splits a query string into tokens.
+//
+// Identifiers, numbers, dates and strings are scanned the same way the
+// tree search language scans them, so that an expression re-rendered from
+// tokens parses to the same TSL tree as the original text.
+type lexer struct {
+	input  string
+	pos    int
+	tokens []Token
+}
+
+// tokenize scans the input and returns the list of tokens, terminated by an EOF token.
+func tokenize(input string) ([]Token, error) {
+	l := &lexer{input: input}
+
+	for {
+		if err := l.skipSpace(); err != nil {
+			return nil, err
+		}
+		if l.pos >= len(l.input) {
+			break
+		}
+
+		if err := l.scanToken(); err != nil {
+			return nil, err
+		}
+	}
+
+	l.tokens = append(l.tokens, Token{Kind: TokenEOF, Pos: len(input), End: len(input)})
+	return l.tokens, nil
+}
+
+// skipSpace skips white space and comments, e.g. "-- comment" to the end of the line, or "/* comment */".
+func (l *lexer) skipSpace() error {
+	for l.pos < len(l.input) {
+		switch {
+		case unicode.IsSpace(rune(l.input[l.pos])):
+			l.pos++
+		case strings.HasPrefix(l.input[l.pos:], "--"):
+			end := strings.IndexByte(l.input[l.pos:], '\n')
+			if end == -1 {
+				l.pos = len(l.input)
+			} else {
+				l.pos += end + 1
+			}
+		case strings.HasPrefix(l.input[l.pos:], "/*"):
+			end := strings.Index(l.input[l.pos+2:], "*/")
+			if end == -1 {
+				return &SyntaxError{
+					Message:  "unterminated comment",
+					Position: l.pos,
+					Input:    l.input,
+				}
+			}
+			l.pos += end + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *lexer) peekAt(offset int) byte {
+	if l.pos+offset >= len(l.input) {
+		return 0
+	}
+	return l.input[l.pos+offset]
+}
+
+func (l *lexer) emit(kind TokenKind, start int) {
+	text := l.input[start:l.pos]
+	l.tokens = append(l.tokens, Token{Kind: kind, Text: text, Value: text, Pos: start, End: l.pos})
+}
+
+func (l *lexer) scanToken() error {
+	start := l.pos
+	c := l.input[l.pos]
+
+	switch {
+	case c == '`':
+		return l.scanIdentifier()
+	case c == '\'' || c == '"':
+		return l.scanString(c)
+	case isDigit(c):
+		l.scanNumberOrDate()
+		return nil
+	case isLetter(c) || c == '_':
+		return l.scanIdentifier()
+	case c == ':' && (isLetter(l.peekAt(1)) || l.peekAt(1) == '_'):
+		l.scanParam()
+		return nil
+	}
+
+	// Two character operators.
+	if l.pos+1 < len(l.input) {
+		switch l.input[l.pos : l.pos+2] {
+		case "!=", "<>", "<=", ">=", "~=", "~!":
+			l.pos += 2
+			l.emit(TokenOperator, start)
+			return nil
+		}
+	}
+
+	switch c {
+	case '=', '<', '>', '+', '-', '*', '/', '%', '!':
+		l.pos++
+		l.emit(TokenOperator, start)
+	case '(', ')', '[', ']', '{', '}', ',', ';':
+		l.pos++
+		l.emit(TokenPunct, start)
+	default:
+		return &SyntaxError{
+			Message:  "unexpected character '" + string(c) + "'",
+			Position: start,
+			Input:    l.input,
+		}
+	}
+
+	return nil
+}
+
+// scanString scans a quoted string, using the same escape sequences as TSL.
+func (l *lexer) scanString(quote byte) error {
+	start := l.pos
+	l.pos++
+
+	var value strings.Builder
+	for l.pos < len(l.input) && l.input[l.pos] != quote {
+		c := l.input[l.pos]
+		l.pos++
+
+		if c != '\\' || l.pos >= len(l.input) {
+			value.WriteByte(c)
+			continue
+		}
+
+		escaped := l.input[l.pos]
+		l.pos++
+		switch escaped {
+		case 'n':
+			value.WriteByte('\n')
+		case 't':
+			value.WriteByte('\t')
+		case 'r':
+			value.WriteByte('\r')
+		case 'b':
+			value.WriteByte('\b')
+		case 'f':
+			value.WriteByte('\f')
+		default:
+			value.WriteByte(escaped)
+		}
+	}
+
+	if l.pos >= len(l.input) {
+		return &SyntaxError{
+			Message:  "unterminated string",
+			Position: start,
+			Input:    l.input,
+		}
+	}
+
+	// Consume closing quote.
+	l.pos++
+
+	l.tokens = append(l.tokens, Token{
+		Kind:  TokenString,
+		Text:  l.input[start:l.pos],
+		Value: value.String(),
+		Quote: quote,
+		Pos:   start,
+		End:   l.pos,
+	})
+	return nil
+}
+
+// scanNumberOrDate scans a date, a RFC3339 timestamp or a number with an optional SI suffix.
+func (l *lexer) scanNumberOrDate() {
+	start := l.pos
+	remaining := l.input[l.pos:]
+
+	if m := rfc3339Pattern.FindString(remaining); m != "" {
+		l.pos += len(m)
+		l.emit(TokenDate, start)
+		return
+	}
+	if m := datePattern.FindString(remaining); m != "" {
+		l.pos += len(m)
+		l.emit(TokenDate, start)
+		return
+	}
+
+	for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
+		l.pos++
+	}
+
+	// Decimal point.
+	if l.peekAt(0) == '.' && isDigit(l.peekAt(1)) {
+		l.pos++
+		for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
+			l.pos++
+		}
+	}
+
+	// Scientific notation.
+	if (l.peekAt(0) == 'e' || l.peekAt(0) == 'E') &&
+		(isDigit(l.peekAt(1)) || ((l.peekAt(1) == '+' || l.peekAt(1) == '-') && isDigit(l.peekAt(2)))) {
+		l.pos += 2
+		for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
+			l.pos++
+		}
+	}
+
+	// SI and IEC suffixes, e.g. 20Gi.
+	if strings.ContainsRune("kKmMgGtTpP", rune(l.peekAt(0))) {
+		l.pos++
+		if l.peekAt(0) == 'i' || l.peekAt(0) == 'I' {
+			l.pos++
+		}
+	}
+
+	l.emit(TokenNumber, start)
+}
+
+// scanIdentifier scans an identifier, including dots, slashes, array subscripts and quoted keys.
+//
+// Keys quoted by double quotes or backticks after a dot, e.g. labels."app.kubernetes.io/name",
+// and label or annotation names quoted by backticks, e.g. `labels.app.kubernetes.io/name`, are
+// rendered as quoted subscripts, e.g. labels['app.kubernetes.io/name'], that TSL scans as part
+// of the identifier and eval resolves as a single key.
+func (l *lexer) scanIdentifier() error {
+	start := l.pos
+
+	var text strings.Builder
+	if l.input[l.pos] == '`' {
+		name, err := l.scanQuotedKey()
+		if err != nil {
+			return err
+		}
+		if !isLetter(name[0]) && name[0] != '_' {
+			return &SyntaxError{Message: "invalid quoted identifier", Position: start, Input: l.input}
+		}
+		text.WriteString(quotedMetadataKey(name))
+	}
+
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch {
+		case c == '.' && (l.peekAt(1) == '"' || l.peekAt(1) == '`'):
+			// Quoted key, e.g. labels."app.kubernetes.io/name".
+			l.pos++
+			key, err := l.scanQuotedKey()
+			if err != nil {
+				return err
+			}
+			text.WriteString("['" + key + "']")
+		case isLetter(c) || isDigit(c) || c == '_' || c == '.' || c == '/':
+			text.WriteByte(c)
+			l.pos++
+		case c == '-' && metadataKeyPrefix(text.String()) != "" && (isLetter(l.peekAt(1)) || isDigit(l.peekAt(1))):
+			// Label and annotation keys may contain hyphens, e.g. labels.pod-template-hash.
+			key := l.scanMetadataKey(text.String())
+			text.Reset()
+			text.WriteString(key)
+		case c == '[':
+			// Array subscript, e.g. containers[0] or containers[*].
+			end := strings.IndexByte(l.input[l.pos:], ']')
+			if end == -1 {
+				end = len(l.input) - l.pos - 1
+			}
+			text.WriteString(l.input[l.pos : l.pos+end+1])
+			l.pos += end + 1
+		default:
+			l.emitIdent(text.String(), start)
+			return nil
+		}
+	}
+
+	l.emitIdent(text.String(), start)
+	return nil
+}
+
+// metadataKeyPrefix returns the prefix of an identifier that is a label or annotation name, e.g.
+// labels. for labels.app, or an empty string for other identifiers.
+func metadataKeyPrefix(name string) string {
+	for _, prefix := range []string{"labels.", "annotations.", "metadata.labels.", "metadata.annotations."} {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) && !strings.ContainsAny(name, "[]") {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// scanMetadataKey scans the rest of a label or annotation name containing hyphens, and returns
+// it rendered as a quoted key, e.g. labels.pod-template-hash as labels['pod-template-hash'],
+// hyphens inside other identifiers are minus operators.
+func (l *lexer) scanMetadataKey(name string) string {
+	prefix := metadataKeyPrefix(name)
+	key := name[len(prefix):]
+
+	end := l.pos
+	for end < len(l.input) {
+		c := l.input[end]
+		if !isLetter(c) && !isDigit(c) && c != '_' && c != '.' && c != '/' && c != '-' {
+			break
+		}
+		end++
+	}
+	// Keys end with an alphanumeric character.
+	for end > l.pos && !isLetter(l.input[end-1]) && !isDigit(l.input[end-1]) {
+		end--
+	}
+
+	key += l.input[l.pos:end]
+	l.pos = end
+	return prefix[:len(prefix)-1] + "['" + key + "']"
+}
+
+// scanQuotedKey scans a key quoted by double quotes or backticks, keys may not contain
+// quotes or brackets, that are not valid in label and annotation names.
+func (l *lexer) scanQuotedKey() (string, error) {
+	start := l.pos
+	quote := l.input[l.pos]
+
+	end := strings.IndexByte(l.input[l.pos+1:], quote)
+	if end == -1 {
+		return "", &SyntaxError{Message: "unterminated quoted identifier", Position: start, Input: l.input}
+	}
+
+	key := l.input[l.pos+1 : l.pos+1+end]
+	if key == "" || strings.ContainsAny(key, "'\"`[]\\") {
+		return "", &SyntaxError{Message: "invalid quoted identifier", Position: start, Input: l.input}
+	}
+
+	l.pos += end + 2
+	return key, nil
+}
+
+// quotedMetadataKey renders a backtick quoted identifier, label and annotation names are quoted
+// as a single key, e.g. labels.app.kubernetes.io/name as labels['app.kubernetes.io/name'].
+func quotedMetadataKey(name string) string {
+	for _, prefix := range []string{"labels.", "annotations."} {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return prefix[:len(prefix)-1] + "['" + name[len(prefix):] + "']"
+		}
+	}
+	return name
+}
+
+// emitIdent adds an identifier token, the text of identifiers with quoted keys
+// differs from the source text.
+func (l *lexer) emitIdent(text string, start int) {
+	l.tokens = append(l.tokens, Token{Kind: TokenIdent, Text: text, Value: text, Pos: start, End: l.pos})
+}
+
+// scanParam scans a query parameter, e.g. :ns or :since.
+func (l *lexer) scanParam() {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.input) && (isLetter(l.input[l.pos]) || isDigit(l.input[l.pos]) || l.input[l.pos] == '_') {
+		l.pos++
+	}
+
+	l.tokens = append(l.tokens, Token{Kind: TokenParam, Text: l.input[start:l.pos], Value: l.input[start+1 : l.pos], Pos: start, End: l.pos})
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
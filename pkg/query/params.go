@@ -0,0 +1,66 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// paramNamePattern matches the values of parameters that may be used in resource names,
+// e.g. a namespace, or a resource name qualified by group.
+var paramNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9.\-]*[a-z0-9])?$`)
+
+// bindParams replaces the parameter tokens by literal tokens of their values, values are never
+// added to the query text, a string value is always a single string literal.
+func bindParams(input string, tokens []Token, params map[string]interface{}) error {
+	for i, t := range tokens {
+		if t.Kind != TokenParam {
+			continue
+		}
+
+		value, ok := params[t.Value]
+		if !ok {
+			return &SyntaxError{
+				Message:  "missing value of parameter " + t.Text,
+				Position: t.Pos,
+				Input:    input,
+			}
+		}
+
+		literal := literalToken(value)
+		literal.Param, literal.Pos, literal.End = t.Value, t.Pos, t.End
+		tokens[i] = literal
+	}
+
+	return nil
+}
+
+// literalToken returns the TSL literal token of a value.
+func literalToken(value interface{}) Token {
+	switch v := value.(type) {
+	case bool:
+		text := strconv.FormatBool(v)
+		return Token{Kind: TokenIdent, Text: text, Value: text}
+	case float64:
+		text := strconv.FormatFloat(v, 'f', -1, 64)
+		return Token{Kind: TokenNumber, Text: text, Value: text}
+	case int64:
+		text := strconv.FormatInt(v, 10)
+		return Token{Kind: TokenNumber, Text: text, Value: text}
+	case time.Time:
+		text := v.Format(time.RFC3339)
+		return Token{Kind: TokenDate, Text: text, Value: text}
+	default:
+		s := fmt.Sprintf("%v", v)
+		return Token{Kind: TokenString, Text: QuoteString(s), Value: s, Quote: '\''}
+	}
+}
+
+// QuoteString renders a string as a TSL string literal, escaping quotes and backslashes,
+// e.g. "it's" as 'it\'s'.
+func QuoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
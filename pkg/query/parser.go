@@ -0,0 +1,849 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// clauses lists the keywords that start a new clause of a SELECT statement.
+var clauses = [][]string{
+	{"FROM"},
+	{"JOIN"},
+	{"INNER", "JOIN"},
+	{"LEFT", "JOIN"},
+	{"LEFT", "OUTER", "JOIN"},
+	{"ON"},
+	{"WHERE"},
+	{"GROUP", "BY"},
+	{"HAVING"},
+	{"ORDER", "BY"},
+	{"LIMIT"},
+	{"OFFSET"},
+	{"UNION"},
+}
+
+// operators lists the TSL keyword operators, that may be followed by
+// parentheses without being function calls, e.g. "any (x > 1)".
+var operators = map[string]bool{
+	"and": true, "or": true, "not": true, "in": true, "is": true, "null": true,
+	"like": true, "ilike": true, "between": true, "true": true, "false": true,
+	"len": true, "any": true, "all": true,
+}
+
+// parser builds a statement from a list of tokens.
+type parser struct {
+	input  string
+	tokens []Token
+	pos    int
+	// nested is the number of enclosing subqueries.
+	nested int
+}
+
+// Parse parses a SELECT query, optionally preceded by a WITH clause, and by EXPLAIN.
+//
+// Example:
+//
+//	stmt, err := query.Parse("SELECT name, status.phase AS phase FROM */pods WHERE phase != 'Running' ORDER BY name LIMIT 5")
+func Parse(input string) (*Statement, error) {
+	return ParseWithParams(input, nil)
+}
+
+// ParseWithParams parses a SELECT query with parameters, e.g. :ns, bound to the values of params.
+//
+// Example:
+//
+//	stmt, err := query.ParseWithParams("SELECT name FROM */pods WHERE namespace = :ns", map[string]interface{}{"ns": "prod"})
+func ParseWithParams(input string, params map[string]interface{}) (*Statement, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := bindParams(input, tokens, params); err != nil {
+		return nil, err
+	}
+
+	p := &parser{input: input, tokens: tokens}
+	explain := p.acceptKeywords("EXPLAIN")
+	with, err := p.parseWith()
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	stmt.With = with
+	stmt.Explain = explain
+
+	// Allow one trailing semicolon.
+	if p.peek().IsPunct(";") {
+		p.next()
+	}
+	if p.peek().Kind != TokenEOF {
+		return nil, p.errorf(p.peek(), "unexpected %q", p.peek().Text)
+	}
+
+	return stmt, nil
+}
+
+// SplitStatements splits a script into its statements, statements are separated by semicolons,
+// and may include comments, e.g. "-- comment" or "/* comment */". Empty statements are skipped.
+// Statements that can not be tokenized, e.g. with an unterminated string, end at the next
+// semicolon, and their errors are reported when they are parsed.
+//
+// Example:
+//
+//	statements := query.SplitStatements("SELECT name FROM pods; -- nodes\nSELECT name FROM nodes;")
+func SplitStatements(script string) []string {
+	l := &lexer{input: script}
+
+	// Statements start at their first token and end at their last token,
+	// comments before and after statements are not included.
+	statements := []string{}
+	start, end := -1, -1
+	split := func() {
+		if start != -1 {
+			statements = append(statements, script[start:end])
+		}
+		start, end = -1, -1
+	}
+
+	for {
+		n := len(l.tokens)
+		err := l.skipSpace()
+		if err == nil && l.pos >= len(l.input) {
+			break
+		}
+		if err == nil {
+			err = l.scanToken()
+		}
+
+		for _, t := range l.tokens[n:] {
+			if !t.IsPunct(";") {
+				if start == -1 {
+					start = t.Pos
+				}
+				end = t.End
+				continue
+			}
+			split()
+		}
+
+		// Statements that can not be tokenized end at the next semicolon, parsing them
+		// reports the error.
+		if err != nil {
+			pos := l.pos
+			if syntaxErr, ok := err.(*SyntaxError); ok {
+				pos = syntaxErr.Position
+			}
+			if start == -1 {
+				start = pos
+			}
+			end = len(script)
+			if i := strings.IndexByte(script[pos:], ';'); i != -1 {
+				end = pos + i
+			}
+			l.pos = end
+			split()
+		}
+	}
+	split()
+
+	return statements
+}
+
+func (p *parser) peek() Token {
+	return p.peekAt(0)
+}
+
+func (p *parser) peekAt(offset int) Token {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *parser) next() Token {
+	t := p.peek()
+	if p.pos < len(p.tokens)-1 {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t Token, format string, args ...interface{}) error {
+	return &SyntaxError{
+		Message:  fmt.Sprintf(format, args...),
+		Position: t.Pos,
+		Input:    p.input,
+	}
+}
+
+// atKeywords checks if the next tokens match a (possibly multi word) keyword.
+func (p *parser) atKeywords(words ...string) bool {
+	for i, w := range words {
+		if !p.peekAt(i).Is(w) {
+			return false
+		}
+	}
+	return true
+}
+
+// acceptKeywords consumes a (possibly multi word) keyword if present.
+func (p *parser) acceptKeywords(words ...string) bool {
+	if !p.atKeywords(words...) {
+		return false
+	}
+	p.pos += len(words)
+	return true
+}
+
+func (p *parser) expectKeywords(words ...string) error {
+	if !p.acceptKeywords(words...) {
+		return p.errorf(p.peek(), "expected %s", strings.Join(words, " "))
+	}
+	return nil
+}
+
+// atClause checks if the next token starts a new clause or ends the statement.
+func (p *parser) atClause() bool {
+	t := p.peek()
+	if t.Kind == TokenEOF || t.IsPunct(";") || p.atSubqueryEnd() {
+		return true
+	}
+
+	for _, c := range clauses {
+		if p.atKeywords(c...) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseQuery parses: select {UNION [ALL] select}
+func (p *parser) parseQuery() (*Statement, error) {
+	stmt, err := p.parseSelect()
+	if err != nil {
+		return nil, err
+	}
+
+	last := stmt
+	for p.peek().Is("UNION") {
+		t := p.next()
+		if len(last.OrderBy) > 0 || last.Limit > 0 || last.Offset > 0 {
+			return nil, p.errorf(t, "ORDER BY, LIMIT and OFFSET must follow the last query of a UNION")
+		}
+
+		union := Union{All: p.acceptKeywords("ALL")}
+		if union.Statement, err = p.parseSelect(); err != nil {
+			return nil, err
+		}
+		stmt.Unions = append(stmt.Unions, union)
+		last = union.Statement
+	}
+
+	// Sort and page the combined rows.
+	if last != stmt {
+		stmt.OrderBy, last.OrderBy = last.OrderBy, nil
+		stmt.Limit, last.Limit = last.Limit, 0
+		stmt.Offset, last.Offset = last.Offset, 0
+	}
+
+	return stmt, nil
+}
+
+// parseSelect parses:
+//
+//	SELECT [DISTINCT] fields FROM resources [joins] [WHERE expr] [GROUP BY exprs] [HAVING expr] [ORDER BY items]
+//	[LIMIT n [OFFSET m] | LIMIT m, n | OFFSET m]
+func (p *parser) parseSelect() (*Statement, error) {
+	var err error
+	stmt := &Statement{}
+
+	if err := p.expectKeywords("SELECT"); err != nil {
+		return nil, err
+	}
+
+	stmt.Distinct = p.acceptKeywords("DISTINCT")
+	if stmt.Fields, err = p.parseFields(); err != nil {
+		return nil, err
+	}
+
+	if err := p.expectKeywords("FROM"); err != nil {
+		return nil, err
+	}
+	if stmt.From, err = p.parseFrom(); err != nil {
+		return nil, err
+	}
+	if stmt.Joins, err = p.parseJoins(); err != nil {
+		return nil, err
+	}
+
+	if p.acceptKeywords("WHERE") {
+		if stmt.Where, err = p.parseExpr("WHERE"); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.acceptKeywords("GROUP", "BY") {
+		if stmt.GroupBy, err = p.parseExprList("GROUP BY"); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.acceptKeywords("HAVING") {
+		if stmt.Having, err = p.parseExpr("HAVING"); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.acceptKeywords("ORDER", "BY") {
+		if stmt.OrderBy, err = p.parseOrderBy(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.acceptKeywords("LIMIT") {
+		if stmt.Limit, err = p.parseCount("LIMIT"); err != nil {
+			return nil, err
+		}
+
+		// LIMIT offset, count
+		if p.peek().IsPunct(",") {
+			p.next()
+			stmt.Offset = stmt.Limit
+			if stmt.Limit, err = p.parseCount("LIMIT"); err != nil {
+				return nil, err
+			}
+
+			if p.peek().Is("OFFSET") {
+				return nil, p.errorf(p.peek(), "OFFSET can not be used with LIMIT offset, count")
+			}
+		}
+	}
+
+	if p.acceptKeywords("OFFSET") {
+		if stmt.Offset, err = p.parseCount("OFFSET"); err != nil {
+			return nil, err
+		}
+	}
+
+	return stmt, nil
+}
+
+// parseFields parses the SELECT list, returns nil for "*".
+func (p *parser) parseFields() ([]Field, error) {
+	if p.peek().IsPunct("*") && p.peekAt(1).Is("FROM") {
+		p.next()
+		return nil, nil
+	}
+
+	fields := []Field{}
+	for {
+		expr, err := p.parseExprUntil("SELECT", func() bool {
+			return p.peek().Is("AS") || p.peek().IsPunct(",")
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		first, last := expr.Tokens[0], expr.Tokens[len(expr.Tokens)-1]
+		field := Field{Expr: expr, Text: p.input[first.Pos:last.End]}
+		if p.acceptKeywords("AS") {
+			t := p.next()
+			if t.Kind != TokenIdent {
+				return nil, p.errorf(t, "expected column name after AS")
+			}
+			field.Alias = t.Text
+		}
+		fields = append(fields, field)
+
+		if !p.peek().IsPunct(",") {
+			return fields, nil
+		}
+		p.next()
+	}
+}
+
+// parseFrom parses a comma separated list of resources.
+func (p *parser) parseFrom() ([]TableRef, error) {
+	refs := []TableRef{}
+	for {
+		ref, err := p.parseTableRef()
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+
+		if !p.peek().IsPunct(",") {
+			return refs, nil
+		}
+		p.next()
+	}
+}
+
+// parseTableRef parses a resource name.
+//
+// Resource names may contain characters that are operators in expressions,
+// e.g. "kube-system/pods" or "*/pods", so a resource is read as the longest
+// run of adjacent tokens, not separated by white space.
+func (p *parser) parseTableRef() (TableRef, error) {
+	first := p.peek()
+	if first.Kind == TokenEOF || first.IsPunct(",") || first.IsPunct(";") || p.atClause() {
+		return TableRef{}, p.errorf(first, "expected resource name")
+	}
+
+	// Namespace sets, e.g. "{prod, staging}/pods", may contain commas and spaces,
+	// and parameters are replaced by their values, e.g. ":ns/pods".
+	var resource strings.Builder
+	braces := 0
+	last := p.next()
+	for {
+		if last.Param != "" {
+			// Parameters are a single name, and can not change the structure of the resource.
+			if !paramNamePattern.MatchString(last.Value) {
+				return TableRef{}, p.errorf(last, "invalid value of parameter :%s in resource name: %q", last.Param, last.Value)
+			}
+			resource.WriteString(last.Value)
+		} else {
+			resource.WriteString(last.Text)
+		}
+		if last.IsPunct("{") {
+			braces++
+		} else if last.IsPunct("}") && braces > 0 {
+			braces--
+		}
+
+		t := p.peek()
+		if t.Kind == TokenEOF || t.IsPunct(";") {
+			break
+		}
+		if braces == 0 && (t.Pos != last.End || t.IsPunct(",") || p.atSubqueryEnd()) {
+			break
+		}
+		resource.WriteString(p.input[last.End:t.Pos])
+		last = p.next()
+	}
+	if braces > 0 {
+		return TableRef{}, p.errorf(last, "expected } after namespace set")
+	}
+
+	ref := TableRef{Resource: resource.String()}
+
+	// Optional table alias, e.g. "*/pods p" or "*/pods AS p".
+	hasAS := p.acceptKeywords("AS")
+	if t := p.peek(); t.Kind == TokenIdent && !p.atClause() {
+		if strings.ContainsAny(t.Text, "./[") {
+			return TableRef{}, p.errorf(t, "invalid table alias: %s", t.Text)
+		}
+		ref.Alias = p.next().Text
+	} else if hasAS {
+		return TableRef{}, p.errorf(t, "expected table alias after AS")
+	}
+
+	return ref, nil
+}
+
+// parseJoins parses: { [INNER | LEFT [OUTER]] JOIN resource ON expr }
+func (p *parser) parseJoins() ([]Join, error) {
+	joins := []Join{}
+	for {
+		var join Join
+
+		switch {
+		case p.acceptKeywords("JOIN"), p.acceptKeywords("INNER", "JOIN"):
+		case p.acceptKeywords("LEFT", "JOIN"), p.acceptKeywords("LEFT", "OUTER", "JOIN"):
+			join.Left = true
+		default:
+			return joins, nil
+		}
+
+		table, err := p.parseTableRef()
+		if err != nil {
+			return nil, err
+		}
+		join.Table = table
+
+		if err := p.expectKeywords("ON"); err != nil {
+			return nil, err
+		}
+		if join.On, err = p.parseExpr("ON"); err != nil {
+			return nil, err
+		}
+
+		joins = append(joins, join)
+	}
+}
+
+// collations are the string collations of the ORDER BY clause.
+var collations = map[string]bool{"binary": true, "nocase": true, "natural": true}
+
+// parseOrderBy parses a comma separated list of sort keys:
+// expr [COLLATE collation] [ASC|DESC] [NULLS FIRST|LAST]
+func (p *parser) parseOrderBy() ([]OrderItem, error) {
+	items := []OrderItem{}
+	for {
+		expr, err := p.parseExprUntil("ORDER BY", func() bool {
+			return p.peek().Is("ASC") || p.peek().Is("DESC") || p.peek().Is("COLLATE") ||
+				p.atKeywords("NULLS", "FIRST") || p.atKeywords("NULLS", "LAST") || p.peek().IsPunct(",")
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		item := OrderItem{Expr: expr}
+		if t := expr.Tokens; len(t) == 1 && t[0].Kind == TokenNumber && t[0].Param == "" {
+			n, err := strconv.Atoi(t[0].Text)
+			if err != nil || n < 1 {
+				return nil, p.errorf(t[0], "invalid ORDER BY position %s, expected a column number", t[0].Text)
+			}
+			item.Position = n
+		}
+
+		if p.acceptKeywords("COLLATE") {
+			t := p.next()
+			name := strings.ToLower(t.Value)
+			if (t.Kind != TokenIdent && t.Kind != TokenString) || !collations[name] {
+				return nil, p.errorf(t, "unknown collation %q, expected binary, nocase or natural", t.Value)
+			}
+			item.Collation = name
+		}
+
+		if p.acceptKeywords("DESC") {
+			item.Descending = true
+		} else {
+			p.acceptKeywords("ASC")
+		}
+
+		switch {
+		case p.acceptKeywords("NULLS", "FIRST"):
+			item.Nulls = "FIRST"
+		case p.acceptKeywords("NULLS", "LAST"):
+			item.Nulls = "LAST"
+		}
+		items = append(items, item)
+
+		if !p.peek().IsPunct(",") {
+			return items, nil
+		}
+		p.next()
+	}
+}
+
+// parseExprList parses a comma separated list of expressions.
+func (p *parser) parseExprList(clause string) ([]*Expr, error) {
+	exprs := []*Expr{}
+	for {
+		expr, err := p.parseExprUntil(clause, func() bool {
+			return p.peek().IsPunct(",")
+		})
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+
+		if !p.peek().IsPunct(",") {
+			return exprs, nil
+		}
+		p.next()
+	}
+}
+
+// parseCount parses a non negative integer.
+func (p *parser) parseCount(clause string) (int, error) {
+	t := p.next()
+	if t.Kind != TokenNumber {
+		return 0, p.errorf(t, "invalid %s value: %q", clause, t.Text)
+	}
+
+	n, err := strconv.Atoi(t.Text)
+	if err != nil || n < 0 {
+		return 0, p.errorf(t, "invalid %s value: %q", clause, t.Text)
+	}
+	return n, nil
+}
+
+// parseExpr parses an expression that ends at the next clause.
+func (p *parser) parseExpr(clause string) (*Expr, error) {
+	return p.parseExprUntil(clause, func() bool { return false })
+}
+
+// parseExprUntil collects the tokens of an expression, up to the next clause
+// or until stop returns true outside of parentheses and brackets.
+func (p *parser) parseExprUntil(clause string, stop func() bool) (*Expr, error) {
+	expr := &Expr{}
+	depth := 0
+	start := p.peek()
+
+	for {
+		t := p.peek()
+		if t.Kind == TokenEOF {
+			break
+		}
+		if depth == 0 && (p.atClause() || stop()) {
+			break
+		}
+
+		if p.atSubquery() {
+			subquery, err := p.parseSubquery()
+			if err != nil {
+				return nil, err
+			}
+			expr.Tokens = append(expr.Tokens, subquery)
+			continue
+		}
+		if t.Is("CASE") {
+			call, err := p.parseCase()
+			if err != nil {
+				return nil, err
+			}
+			expr.Tokens = append(expr.Tokens, call)
+			continue
+		}
+		if p.atCall() {
+			call, err := p.parseCall()
+			if err != nil {
+				return nil, err
+			}
+			expr.Tokens = append(expr.Tokens, call)
+			continue
+		}
+
+		switch {
+		case t.IsPunct("(") || t.IsPunct("["):
+			depth++
+		case t.IsPunct(")") || t.IsPunct("]"):
+			depth--
+			if depth < 0 {
+				return nil, p.errorf(t, "unbalanced %q", t.Text)
+			}
+		}
+
+		expr.Tokens = append(expr.Tokens, p.next())
+	}
+
+	if depth != 0 {
+		return nil, p.errorf(p.peek(), "missing closing parenthesis")
+	}
+	if len(expr.Tokens) == 0 {
+		return nil, p.errorf(start, "%s clause cannot be empty", clause)
+	}
+
+	return expr, nil
+}
+
+// atSubquery checks if the next tokens start a subquery, e.g. "(SELECT" or "EXISTS (SELECT".
+func (p *parser) atSubquery() bool {
+	if p.peek().Is("EXISTS") {
+		return p.peekAt(1).IsPunct("(") && p.peekAt(2).Is("SELECT")
+	}
+	return p.peek().IsPunct("(") && p.peekAt(1).Is("SELECT")
+}
+
+// parseWith parses: [WITH name AS ( SELECT ... ) {, name AS ( SELECT ... )}]
+func (p *parser) parseWith() ([]CTE, error) {
+	if !p.acceptKeywords("WITH") {
+		return nil, nil
+	}
+
+	ctes := []CTE{}
+	for {
+		name := p.next()
+		if name.Kind != TokenIdent || strings.ContainsAny(name.Text, "./[") {
+			return nil, p.errorf(name, "expected name of WITH query")
+		}
+		for _, cte := range ctes {
+			if strings.EqualFold(cte.Name, name.Text) {
+				return nil, p.errorf(name, "duplicate name of WITH query: %s", name.Text)
+			}
+		}
+		if err := p.expectKeywords("AS"); err != nil {
+			return nil, err
+		}
+
+		open := p.next()
+		if !open.IsPunct("(") || !p.peek().Is("SELECT") {
+			return nil, p.errorf(open, "expected ( SELECT after AS")
+		}
+
+		p.nested++
+		stmt, err := p.parseQuery()
+		p.nested--
+		if err != nil {
+			return nil, err
+		}
+
+		end := p.next()
+		if !end.IsPunct(")") {
+			return nil, p.errorf(end, "missing closing parenthesis of WITH query")
+		}
+		ctes = append(ctes, CTE{
+			Name:      name.Text,
+			Statement: stmt,
+			Text:      strings.TrimSpace(p.input[open.End:end.Pos]),
+		})
+
+		if !p.peek().IsPunct(",") {
+			return ctes, nil
+		}
+		p.next()
+	}
+}
+
+// atSubqueryEnd checks if the next token closes the parentheses of a subquery.
+func (p *parser) atSubqueryEnd() bool {
+	return p.nested > 0 && p.peek().IsPunct(")")
+}
+
+// parseSubquery parses: [EXISTS] ( SELECT ... )
+func (p *parser) parseSubquery() (Token, error) {
+	start := p.peek()
+	call := &Call{Args: []*Expr{}}
+	if start.Is("EXISTS") {
+		call.Name = p.next().Text
+	}
+	open := p.next()
+
+	p.nested++
+	stmt, err := p.parseQuery()
+	p.nested--
+	if err != nil {
+		return Token{}, err
+	}
+
+	end := p.next()
+	if !end.IsPunct(")") {
+		return Token{}, p.errorf(end, "missing closing parenthesis of subquery")
+	}
+	call.Subquery = &Subquery{
+		Statement: stmt,
+		Text:      strings.TrimSpace(p.input[open.End:end.Pos]),
+	}
+
+	return Token{
+		Kind:  TokenCall,
+		Text:  call.String(),
+		Value: call.String(),
+		Pos:   start.Pos,
+		End:   end.End,
+		Call:  call,
+	}, nil
+}
+
+// atCall checks if the next tokens start a function call, e.g. "lower(".
+func (p *parser) atCall() bool {
+	t := p.peek()
+	if t.Kind != TokenIdent || !p.peekAt(1).IsPunct("(") {
+		return false
+	}
+
+	if operators[strings.ToLower(t.Text)] || strings.ContainsAny(t.Text, "./[") {
+		return false
+	}
+	return true
+}
+
+// parseCall parses: name ( [* | expr {, expr}] )
+func (p *parser) parseCall() (Token, error) {
+	name := p.next()
+	p.next()
+
+	call := &Call{Name: name.Text, Args: []*Expr{}}
+	switch {
+	case p.peek().IsPunct("*") && p.peekAt(1).IsPunct(")"):
+		p.next()
+		call.Star = true
+	case !p.peek().IsPunct(")"):
+		for {
+			arg, err := p.parseExprUntil("function argument", func() bool {
+				return p.peek().IsPunct(",") || p.peek().IsPunct(")")
+			})
+			if err != nil {
+				return Token{}, err
+			}
+			call.Args = append(call.Args, arg)
+
+			if !p.peek().IsPunct(",") {
+				break
+			}
+			p.next()
+		}
+	}
+
+	end := p.next()
+	if !end.IsPunct(")") {
+		return Token{}, p.errorf(end, "missing closing parenthesis of %s", name.Text)
+	}
+
+	return Token{
+		Kind:  TokenCall,
+		Text:  call.String(),
+		Value: call.String(),
+		Pos:   name.Pos,
+		End:   end.End,
+		Call:  call,
+	}, nil
+}
+
+// parseCase parses: CASE [expr] WHEN expr THEN expr {WHEN expr THEN expr} [ELSE expr] END
+func (p *parser) parseCase() (Token, error) {
+	start := p.next()
+	c := &Case{}
+
+	if !p.peek().Is("WHEN") {
+		operand, err := p.parseExprUntil("CASE", func() bool { return p.peek().Is("WHEN") })
+		if err != nil {
+			return Token{}, err
+		}
+		c.Operand = operand
+	}
+
+	for p.peek().Is("WHEN") {
+		p.next()
+		cond, err := p.parseExprUntil("WHEN", func() bool { return p.peek().Is("THEN") })
+		if err != nil {
+			return Token{}, err
+		}
+		if !p.peek().Is("THEN") {
+			return Token{}, p.errorf(p.peek(), "expected THEN")
+		}
+		p.next()
+
+		result, err := p.parseExprUntil("THEN", func() bool {
+			t := p.peek()
+			return t.Is("WHEN") || t.Is("ELSE") || t.Is("END")
+		})
+		if err != nil {
+			return Token{}, err
+		}
+		c.Whens = append(c.Whens, When{Cond: cond, Result: result})
+	}
+	if len(c.Whens) == 0 {
+		return Token{}, p.errorf(p.peek(), "expected WHEN")
+	}
+
+	if p.peek().Is("ELSE") {
+		p.next()
+		result, err := p.parseExprUntil("ELSE", func() bool { return p.peek().Is("END") })
+		if err != nil {
+			return Token{}, err
+		}
+		c.Else = result
+	}
+
+	end := p.next()
+	if !end.Is("END") {
+		return Token{}, p.errorf(end, "expected END of CASE expression")
+	}
+
+	call := &Call{Name: start.Text, Args: []*Expr{}, Case: c}
+	return Token{
+		Kind:  TokenCall,
+		Text:  call.String(),
+		Value: call.String(),
+		Pos:   start.Pos,
+		End:   end.End,
+		Call:  call,
+	}, nil
+}
@@ -0,0 +1,597 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		explain  bool
+		distinct bool
+		fields   []string
+		aliases  []string
+		from     []string
+		joins    []string
+		where    string
+		groupBy  []string
+		having   string
+		orderBy  []string
+		desc     []bool
+		unions   []string
+		with     []string
+		limit    int
+		offset   int
+		wantErr  bool
+	}{
+		{
+			name:  "select all",
+			query: "SELECT * FROM pods",
+			from:  []string{"pods"},
+		},
+		{
+			name:    "full query",
+			query:   "select name, status.phase as phase from */pods where phase != 'Running' order by name desc, phase limit 5",
+			fields:  []string{"name", "status.phase"},
+			aliases: []string{"", "phase"},
+			from:    []string{"*/pods"},
+			where:   "phase != 'Running'",
+			orderBy: []string{"name", "phase"},
+			desc:    []bool{true, false},
+			limit:   5,
+		},
+		{
+			name:    "comments",
+			query:   "-- running pods\nSELECT name /* , namespace */ FROM pods\nWHERE phase = 'Running' -- and not pending",
+			fields:  []string{"name"},
+			aliases: []string{""},
+			from:    []string{"pods"},
+			where:   "phase = 'Running'",
+		},
+		{
+			name:    "clause keywords inside string literal",
+			query:   "SELECT name FROM pods WHERE name = 'order by x limit 3'",
+			fields:  []string{"name"},
+			aliases: []string{""},
+			from:    []string{"pods"},
+			where:   "name = 'order by x limit 3'",
+		},
+		{
+			name:    "irregular white space",
+			query:   "SELECT name\nFROM  kube-system/pods\tWHERE name ~= '^etcd'\n ORDER   BY name\nLIMIT\t2",
+			fields:  []string{"name"},
+			aliases: []string{""},
+			from:    []string{"kube-system/pods"},
+			where:   "name ~= '^etcd'",
+			orderBy: []string{"name"},
+			desc:    []bool{false},
+			limit:   2,
+		},
+		{
+			name:    "array subscripts and SI units",
+			query:   "SELECT spec.containers[0].image FROM deployments WHERE spec.containers[0].resources.requests.memory < 512Mi and len spec.containers[*] in [1, 2];",
+			fields:  []string{"spec.containers[0].image"},
+			aliases: []string{""},
+			from:    []string{"deployments"},
+			where:   "spec.containers[0].resources.requests.memory < 512Mi and len spec.containers[*] in [ 1 , 2 ]",
+		},
+		{
+			name:    "dates",
+			query:   "SELECT name FROM pods WHERE created > 2025-02-20T11:12:38Z",
+			fields:  []string{"name"},
+			aliases: []string{""},
+			from:    []string{"pods"},
+			where:   "created > 2025-02-20T11:12:38Z",
+		},
+		{
+			name:    "hyphenated label keys",
+			query:   "SELECT name, labels.pod-template-hash, annotations.example.com/owner-name, labels.app.kubernetes.io/part-of FROM pods WHERE labels.controller-revision-hash = 'abc' AND spec.replicas-1 > 0 ORDER BY metadata.labels.pod-template-hash",
+			fields:  []string{"name", "labels['pod-template-hash']", "annotations['example.com/owner-name']", "labels['app.kubernetes.io/part-of']"},
+			aliases: []string{"", "", "", ""},
+			from:    []string{"pods"},
+			where:   "labels['controller-revision-hash'] = 'abc' AND spec.replicas - 1 > 0",
+			orderBy: []string{"metadata.labels['pod-template-hash']"},
+			desc:    []bool{false},
+		},
+		{
+			name:    "join",
+			query:   "SELECT p.name, n.labels.zone FROM */pods p LEFT JOIN nodes AS n ON p.spec.nodeName = n.name WHERE n.labels.zone = 'east'",
+			fields:  []string{"p.name", "n.labels.zone"},
+			aliases: []string{"", ""},
+			from:    []string{"*/pods"},
+			joins:   []string{"LEFT nodes n ON p.spec.nodeName = n.name"},
+			where:   "n.labels.zone = 'east'",
+		},
+		{
+			name:    "group by with aggregate functions",
+			query:   "SELECT namespace, COUNT(*) AS total, sum( spec.replicas ) FROM deployments GROUP BY namespace ORDER BY COUNT(*) DESC",
+			fields:  []string{"namespace", "COUNT(*)", "sum(spec.replicas)"},
+			aliases: []string{"", "total", ""},
+			from:    []string{"deployments"},
+			groupBy: []string{"namespace"},
+			orderBy: []string{"COUNT(*)"},
+			desc:    []bool{true},
+		},
+		{
+			name:    "function calls and operators with parentheses",
+			query:   "SELECT name FROM pods WHERE any (spec.containers[*].ports[*].containerPort > 80) and concat(name, 'x', lower(namespace)) = 'a'",
+			from:    []string{"pods"},
+			fields:  []string{"name"},
+			aliases: []string{""},
+			where:   "any ( spec.containers[*].ports[*].containerPort > 80 ) and concat(name, 'x', lower(namespace)) = 'a'",
+		},
+		{
+			name:    "case expressions",
+			query:   "SELECT name, CASE WHEN status.containerStatuses[0].restartCount > 5 THEN 'flapping' ELSE 'ok' END AS health FROM pods ORDER BY CASE phase WHEN 'Failed' THEN 0 ELSE 1 END",
+			fields:  []string{"name", "CASE WHEN status.containerStatuses[0].restartCount > 5 THEN 'flapping' ELSE 'ok' END"},
+			aliases: []string{"", "health"},
+			from:    []string{"pods"},
+			orderBy: []string{"CASE phase WHEN 'Failed' THEN 0 ELSE 1 END"},
+			desc:    []bool{false},
+		},
+		{
+			name:    "nested case expressions in group by",
+			query:   "SELECT COUNT(*) FROM pods WHERE CASE WHEN a = 1 THEN lower(name) END = 'x' GROUP BY CASE WHEN a > 1 THEN CASE WHEN b THEN 'b' END ELSE 'c' END",
+			fields:  []string{"COUNT(*)"},
+			aliases: []string{""},
+			from:    []string{"pods"},
+			where:   "CASE WHEN a = 1 THEN lower(name) END = 'x'",
+			groupBy: []string{"CASE WHEN a > 1 THEN CASE WHEN b THEN 'b' END ELSE 'c' END"},
+		},
+		{
+			name:    "subqueries",
+			query:   "SELECT name FROM */pvc p WHERE name NOT IN (SELECT spec.volumes[*].persistentVolumeClaim.claimName FROM */pods) or EXISTS (SELECT * FROM pods WHERE p.name = name LIMIT 1)",
+			fields:  []string{"name"},
+			aliases: []string{""},
+			from:    []string{"*/pvc"},
+			where:   "name NOT IN (SELECT spec.volumes[*].persistentVolumeClaim.claimName FROM */pods) or EXISTS(SELECT * FROM pods WHERE p.name = name LIMIT 1)",
+		},
+		{
+			name:    "nested subqueries",
+			query:   "SELECT name FROM pods WHERE (name IN (SELECT lower(name) FROM nodes WHERE name IN (SELECT name FROM ns/pods))) ORDER BY name",
+			fields:  []string{"name"},
+			aliases: []string{""},
+			from:    []string{"pods"},
+			where:   "( name IN (SELECT lower(name) FROM nodes WHERE name IN (SELECT name FROM ns/pods)) )",
+			orderBy: []string{"name"},
+			desc:    []bool{false},
+		},
+		{
+			name:    "qualified resource names",
+			query:   "SELECT name FROM events.events.k8s.io, */apps/v1/deployments d, ns/deployments.v1.apps",
+			fields:  []string{"name"},
+			aliases: []string{""},
+			from:    []string{"events.events.k8s.io", "*/apps/v1/deployments", "ns/deployments.v1.apps"},
+		},
+		{
+			name:    "quoted identifiers",
+			query:   "SELECT labels.\"app.kubernetes.io/name\" AS app, `annotations.example.com/owner`, metadata.labels.`app.kubernetes.io/part-of` FROM pods WHERE labels.\"app.kubernetes.io/name\" = \"web\" ORDER BY `labels.app.kubernetes.io/name`",
+			fields:  []string{"labels['app.kubernetes.io/name']", "annotations['example.com/owner']", "metadata.labels['app.kubernetes.io/part-of']"},
+			aliases: []string{"app", "", ""},
+			from:    []string{"pods"},
+			where:   "labels['app.kubernetes.io/name'] = \"web\"",
+			orderBy: []string{"labels['app.kubernetes.io/name']"},
+			desc:    []bool{false},
+		},
+		{
+			name:    "with queries",
+			query:   "WITH unready AS (SELECT * FROM */pods WHERE phase != 'Running'), counts AS (SELECT namespace, COUNT(*) AS pods FROM unready GROUP BY namespace) SELECT namespace, pods FROM counts ORDER BY pods DESC",
+			fields:  []string{"namespace", "pods"},
+			aliases: []string{"", ""},
+			from:    []string{"counts"},
+			orderBy: []string{"pods"},
+			desc:    []bool{true},
+			with:    []string{"unready AS (SELECT * FROM */pods WHERE phase != 'Running') FROM */pods", "counts AS (SELECT namespace, COUNT(*) AS pods FROM unready GROUP BY namespace) FROM unready"},
+		},
+		{
+			name:    "explain",
+			query:   "EXPLAIN WITH web AS (SELECT * FROM pods WHERE labels.app = 'web') SELECT name FROM web ORDER BY name LIMIT 3",
+			explain: true,
+			fields:  []string{"name"},
+			aliases: []string{""},
+			from:    []string{"web"},
+			orderBy: []string{"name"},
+			desc:    []bool{false},
+			with:    []string{"web AS (SELECT * FROM pods WHERE labels.app = 'web') FROM pods"},
+			limit:   3,
+		},
+		{
+			name:    "namespace patterns",
+			query:   "SELECT name FROM team-*/pods, {prod, staging}/deployments d, !kube-*/pods p WHERE name ~= 'x'",
+			fields:  []string{"name"},
+			aliases: []string{""},
+			from:    []string{"team-*/pods", "{prod, staging}/deployments", "!kube-*/pods"},
+			where:   "name ~= 'x'",
+		},
+		{
+			name:    "multiple resources",
+			query:   "SELECT name, spec.replicas FROM */deployments, */statefulsets s, default/daemonsets WHERE spec.replicas > 1",
+			fields:  []string{"name", "spec.replicas"},
+			aliases: []string{"", ""},
+			from:    []string{"*/deployments", "*/statefulsets", "default/daemonsets"},
+			where:   "spec.replicas > 1",
+		},
+		{
+			name:    "union",
+			query:   "SELECT name, namespace FROM */deployments WHERE spec.replicas > 1 UNION ALL SELECT name, namespace FROM */statefulsets UNION SELECT name, namespace FROM */daemonsets WHERE name ~= 'x' ORDER BY name DESC LIMIT 5 OFFSET 1",
+			fields:  []string{"name", "namespace"},
+			aliases: []string{"", ""},
+			from:    []string{"*/deployments"},
+			where:   "spec.replicas > 1",
+			unions:  []string{"UNION ALL */statefulsets WHERE ", "UNION */daemonsets WHERE name ~= 'x'"},
+			orderBy: []string{"name"},
+			desc:    []bool{true},
+			limit:   5,
+			offset:  1,
+		},
+		{
+			name:    "group by with having",
+			query:   "SELECT namespace FROM pods GROUP BY namespace HAVING COUNT(*) > 50 and namespace ~= 'prod' LIMIT 3",
+			fields:  []string{"namespace"},
+			aliases: []string{""},
+			from:    []string{"pods"},
+			groupBy: []string{"namespace"},
+			having:  "COUNT(*) > 50 and namespace ~= 'prod'",
+			limit:   3,
+		},
+		{
+			name:     "select distinct",
+			query:    "SELECT DISTINCT spec.nodeName, namespace FROM */pods",
+			distinct: true,
+			fields:   []string{"spec.nodeName", "namespace"},
+			aliases:  []string{"", ""},
+			from:     []string{"*/pods"},
+		},
+		{
+			name:    "limit and offset",
+			query:   "SELECT name FROM pods ORDER BY name LIMIT 10 OFFSET 20",
+			fields:  []string{"name"},
+			aliases: []string{""},
+			from:    []string{"pods"},
+			orderBy: []string{"name"},
+			desc:    []bool{false},
+			limit:   10,
+			offset:  20,
+		},
+		{
+			name:    "limit offset, count",
+			query:   "SELECT name FROM pods LIMIT 20, 10",
+			fields:  []string{"name"},
+			aliases: []string{""},
+			from:    []string{"pods"},
+			limit:   10,
+			offset:  20,
+		},
+		{
+			name:    "offset without limit",
+			query:   "SELECT name FROM pods ORDER BY name OFFSET 5",
+			fields:  []string{"name"},
+			aliases: []string{""},
+			from:    []string{"pods"},
+			orderBy: []string{"name"},
+			desc:    []bool{false},
+			offset:  5,
+		},
+		{name: "limit offset, count with offset", query: "SELECT name FROM pods LIMIT 20, 10 OFFSET 5", wantErr: true},
+		{name: "negative offset", query: "SELECT name FROM pods OFFSET -5", wantErr: true},
+		{name: "empty having", query: "SELECT namespace FROM pods GROUP BY namespace HAVING ORDER BY namespace", wantErr: true},
+		{name: "unclosed function call", query: "SELECT COUNT(* FROM pods", wantErr: true},
+		{name: "case without end", query: "SELECT CASE WHEN a = 1 THEN 'x' FROM pods", wantErr: true},
+		{name: "case without when", query: "SELECT CASE ELSE 'x' END FROM pods", wantErr: true},
+		{name: "case without then", query: "SELECT CASE WHEN a = 1 'x' END FROM pods", wantErr: true},
+		{name: "unclosed subquery", query: "SELECT name FROM pods WHERE name IN (SELECT name FROM nodes", wantErr: true},
+		{name: "subquery without from", query: "SELECT name FROM pods WHERE EXISTS (SELECT name)", wantErr: true},
+		{name: "order by before union", query: "SELECT name FROM pods ORDER BY name UNION SELECT name FROM nodes", wantErr: true},
+		{name: "limit before union", query: "SELECT name FROM pods LIMIT 1 UNION ALL SELECT name FROM nodes", wantErr: true},
+		{name: "unterminated quoted identifier", query: "SELECT labels.\"app FROM pods", wantErr: true},
+		{name: "empty quoted identifier", query: "SELECT labels.`` FROM pods", wantErr: true},
+		{name: "with without parentheses", query: "WITH x AS SELECT name FROM pods SELECT name FROM x", wantErr: true},
+		{name: "with without select", query: "WITH x AS (SELECT name FROM pods)", wantErr: true},
+		{name: "unclosed with", query: "WITH x AS (SELECT name FROM pods SELECT name FROM x", wantErr: true},
+		{name: "duplicate with name", query: "WITH x AS (SELECT name FROM pods), x AS (SELECT name FROM nodes) SELECT name FROM x", wantErr: true},
+		{name: "explain after with", query: "WITH x AS (SELECT name FROM pods) EXPLAIN SELECT name FROM x", wantErr: true},
+		{name: "unclosed namespace set", query: "SELECT name FROM {prod,staging/pods", wantErr: true},
+		{name: "union without select", query: "SELECT name FROM pods UNION ALL name FROM nodes", wantErr: true},
+		{name: "empty function argument", query: "SELECT lower(name,) FROM pods", wantErr: true},
+		{name: "join without on", query: "SELECT name FROM pods JOIN nodes WHERE name = 'x'", wantErr: true},
+		{name: "missing select", query: "name FROM pods", wantErr: true},
+		{name: "missing from", query: "SELECT name", wantErr: true},
+		{name: "empty where", query: "SELECT name FROM pods WHERE ORDER BY name", wantErr: true},
+		{name: "unterminated string", query: "SELECT name FROM pods WHERE name = 'x", wantErr: true},
+		{name: "unbalanced parenthesis", query: "SELECT name FROM pods WHERE (a = 1", wantErr: true},
+		{name: "negative limit", query: "SELECT name FROM pods LIMIT -1", wantErr: true},
+		{name: "trailing tokens", query: "SELECT name FROM pods LIMIT 1 2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, err := Parse(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if stmt.Explain != tt.explain {
+				t.Errorf("Parse() explain = %v, want %v", stmt.Explain, tt.explain)
+			}
+
+			if stmt.Distinct != tt.distinct {
+				t.Errorf("Parse() distinct = %v, want %v", stmt.Distinct, tt.distinct)
+			}
+
+			var fields, aliases []string
+			for _, f := range stmt.Fields {
+				fields = append(fields, f.Expr.String())
+				aliases = append(aliases, f.Alias)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) || !reflect.DeepEqual(aliases, tt.aliases) {
+				t.Errorf("Parse() fields = %v %v, want %v %v", fields, aliases, tt.fields, tt.aliases)
+			}
+
+			var from []string
+			for _, r := range stmt.From {
+				from = append(from, r.Resource)
+			}
+			if !reflect.DeepEqual(from, tt.from) {
+				t.Errorf("Parse() from = %v, want %v", from, tt.from)
+			}
+
+			var joins []string
+			for _, j := range stmt.Joins {
+				kind := "INNER"
+				if j.Left {
+					kind = "LEFT"
+				}
+				joins = append(joins, kind+" "+j.Table.Resource+" "+j.Table.Alias+" ON "+j.On.String())
+			}
+			if !reflect.DeepEqual(joins, tt.joins) {
+				t.Errorf("Parse() joins = %v, want %v", joins, tt.joins)
+			}
+
+			if got := stmt.Where.String(); got != tt.where {
+				t.Errorf("Parse() where = %q, want %q", got, tt.where)
+			}
+
+			var groupBy []string
+			for _, g := range stmt.GroupBy {
+				groupBy = append(groupBy, g.String())
+			}
+			if !reflect.DeepEqual(groupBy, tt.groupBy) {
+				t.Errorf("Parse() group by = %v, want %v", groupBy, tt.groupBy)
+			}
+
+			if got := stmt.Having.String(); got != tt.having {
+				t.Errorf("Parse() having = %q, want %q", got, tt.having)
+			}
+
+			var orderBy []string
+			var desc []bool
+			for _, o := range stmt.OrderBy {
+				orderBy = append(orderBy, o.Expr.String())
+				desc = append(desc, o.Descending)
+			}
+			if !reflect.DeepEqual(orderBy, tt.orderBy) || !reflect.DeepEqual(desc, tt.desc) {
+				t.Errorf("Parse() order by = %v %v, want %v %v", orderBy, desc, tt.orderBy, tt.desc)
+			}
+
+			var unions []string
+			for _, u := range stmt.Unions {
+				kind := "UNION"
+				if u.All {
+					kind = "UNION ALL"
+				}
+				unions = append(unions, kind+" "+u.Statement.From[0].Resource+" WHERE "+u.Statement.Where.String())
+
+				if len(u.Statement.OrderBy) > 0 || u.Statement.Limit > 0 || u.Statement.Offset > 0 {
+					t.Errorf("Parse() union order by, limit or offset not moved to the first statement")
+				}
+			}
+			if !reflect.DeepEqual(unions, tt.unions) {
+				t.Errorf("Parse() unions = %v, want %v", unions, tt.unions)
+			}
+
+			var with []string
+			for _, cte := range stmt.With {
+				with = append(with, cte.Name+" AS ("+cte.Text+") FROM "+cte.Statement.From[0].Resource)
+			}
+			if !reflect.DeepEqual(with, tt.with) {
+				t.Errorf("Parse() with = %v, want %v", with, tt.with)
+			}
+
+			if stmt.Limit != tt.limit || stmt.Offset != tt.offset {
+				t.Errorf("Parse() limit = %d offset = %d, want %d %d", stmt.Limit, stmt.Offset, tt.limit, tt.offset)
+			}
+		})
+	}
+}
+
+func TestParseWithParams(t *testing.T) {
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	params := map[string]interface{}{
+		"ns":      "prod",
+		"name":    "it's' OR name != '",
+		"since":   since,
+		"min":     float64(3),
+		"ratio":   1.5,
+		"ready":   true,
+		"limit":   float64(10),
+		"slashed": "kube-system/apps",
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		from    string
+		where   string
+		limit   int
+		wantErr bool
+	}{
+		{
+			name:  "string, date and number parameters",
+			query: "SELECT name FROM */pods WHERE namespace = :ns AND created > :since AND restarts >= :min",
+			from:  "*/pods",
+			where: "namespace = 'prod' AND created > 2025-01-01T00:00:00Z AND restarts >= 3",
+		},
+		{
+			name:  "quotes in string parameters",
+			query: "SELECT name FROM pods WHERE name = :name",
+			from:  "pods",
+			where: `name = 'it\'s\' OR name != \''`,
+		},
+		{
+			name:  "float and boolean parameters",
+			query: "SELECT name FROM pods WHERE ratio < :ratio AND ready = :ready LIMIT :limit",
+			from:  "pods",
+			where: "ratio < 1.5 AND ready = true",
+			limit: 10,
+		},
+		{
+			name:  "parameter in resource name",
+			query: "SELECT name FROM :ns/pods",
+			from:  "prod/pods",
+		},
+		{name: "missing parameter", query: "SELECT name FROM pods WHERE namespace = :namespace", wantErr: true},
+		{name: "structured parameter in resource name", query: "SELECT name FROM :slashed/pods", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, err := ParseWithParams(tt.query, params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWithParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := stmt.From[0].Resource; got != tt.from {
+				t.Errorf("ParseWithParams() from = %q, want %q", got, tt.from)
+			}
+			if got := stmt.Where.String(); got != tt.where {
+				t.Errorf("ParseWithParams() where = %q, want %q", got, tt.where)
+			}
+			if stmt.Limit != tt.limit {
+				t.Errorf("ParseWithParams() limit = %d, want %d", stmt.Limit, tt.limit)
+			}
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "single statement",
+			script: "SELECT name FROM pods",
+			want:   []string{"SELECT name FROM pods"},
+		},
+		{
+			name:   "statements and comments",
+			script: "-- pods\nSELECT name FROM pods; /* all\nnodes */ SELECT name FROM nodes -- ; not a separator\n;\n",
+			want:   []string{"SELECT name FROM pods", "SELECT name FROM nodes"},
+		},
+		{
+			name:   "semicolons in strings",
+			script: "SELECT name FROM pods WHERE name = 'a;b';;SELECT name FROM nodes",
+			want:   []string{"SELECT name FROM pods WHERE name = 'a;b'", "SELECT name FROM nodes"},
+		},
+		{
+			name:   "only comments",
+			script: "-- nothing to do\n/* at all */;",
+			want:   []string{},
+		},
+		{
+			name:   "unterminated comment",
+			script: "SELECT name FROM pods; SELECT name FROM nodes /* comment",
+			want:   []string{"SELECT name FROM pods", "SELECT name FROM nodes /* comment"},
+		},
+		{
+			name:   "unexpected character",
+			script: "SELECT name FROM pods WHERE name ~ 'a'; SELECT name FROM nodes",
+			want:   []string{"SELECT name FROM pods WHERE name ~ 'a'", "SELECT name FROM nodes"},
+		},
+		{
+			name:   "unterminated string",
+			script: "SELECT name FROM pods; SELECT name FROM nodes WHERE name = 'a; SELECT name FROM pvc",
+			want:   []string{"SELECT name FROM pods", "SELECT name FROM nodes WHERE name = 'a", "SELECT name FROM pvc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitStatements(tt.script)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitStatements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOrderBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "expressions and positions",
+			query: "SELECT name, spec.replicas FROM deployments ORDER BY 2 DESC, lower(name), status.replicas - status.readyReplicas",
+			want:  []string{"2 position=2 desc", "lower(name)", "status.replicas - status.readyReplicas"},
+		},
+		{
+			name:  "nulls and collations",
+			query: "SELECT * FROM pods ORDER BY labels.tier NULLS FIRST, name COLLATE natural DESC NULLS LAST, namespace COLLATE NOCASE ASC",
+			want:  []string{"labels.tier nulls=FIRST", "name collate=natural desc nulls=LAST", "namespace collate=nocase"},
+		},
+		{
+			name:  "field named nulls",
+			query: "SELECT * FROM pods ORDER BY nulls DESC",
+			want:  []string{"nulls desc"},
+		},
+		{name: "unknown collation", query: "SELECT * FROM pods ORDER BY name COLLATE french", wantErr: true},
+		{name: "zero position", query: "SELECT name FROM pods ORDER BY 0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, err := Parse(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var got []string
+			for _, item := range stmt.OrderBy {
+				s := item.Expr.String()
+				if item.Position > 0 {
+					s += fmt.Sprintf(" position=%d", item.Position)
+				}
+				if item.Collation != "" {
+					s += " collate=" + item.Collation
+				}
+				if item.Descending {
+					s += " desc"
+				}
+				if item.Nulls != "" {
+					s += " nulls=" + item.Nulls
+				}
+				got = append(got, s)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() order by = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}